@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-event-crud/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupResponse describes a backup written by the server.
+type backupResponse struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AdminMiddleware only lets through users whose email is listed in auth.admin_emails. It
+// must run after AuthMiddleware.
+func (app *application) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.GetUserFromContext(c)
+		if !app.adminEmails[strings.ToLower(user.Email)] {
+			app.errorResponse(c, http.StatusForbidden, codeAdminRequired, "This endpoint is only available to administrators")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// createBackup godoc
+//
+//	@Summary		Back up the database
+//	@Description	Write a consistent copy of the live SQLite database to the backup directory (requires an administrator)
+//	@Tags			admin
+//	@Produce		json
+//	@Success		201	{object}	backupResponse
+//	@Failure		401	{object}	problem
+//	@Failure		403	{object}	problem
+//	@Failure		409	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Failure		501	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/admin/backup [post]
+func (app *application) createBackup(c *gin.Context) {
+	if !app.backupMu.TryLock() {
+		app.errorResponse(c, http.StatusConflict, codeBackupInProgress, "Another backup is already running")
+		return
+	}
+	defer app.backupMu.Unlock()
+
+	if err := os.MkdirAll(app.config.Backup.Dir, 0o755); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	createdAt := time.Now().UTC()
+	path := database.BackupPath(app.config.Backup.Dir, createdAt)
+
+	if err := database.Backup(c.Request.Context(), app.db, path); err != nil {
+		if errors.Is(err, database.ErrBackupUnsupported) {
+			app.errorResponse(c, http.StatusNotImplemented, codeBackupUnsupported, "Online backups are only supported for SQLite databases")
+			return
+		}
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, backupResponse{Path: path, Size: info.Size(), CreatedAt: createdAt})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"go-event-crud/internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	batchModeAtomic   = "atomic"
+	maxBatchAttendees = 500
+)
+
+// attendeeRef identifies a user by exactly one of ID or email.
+type attendeeRef struct {
+	UserId int    `json:"userId" binding:"required_without=Email,excluded_with=Email"`
+	Email  string `json:"email" binding:"required_without=UserId,excluded_with=UserId,omitempty,email"`
+}
+
+type batchAttendeesRequest struct {
+	Mode   string        `json:"mode" binding:"required,oneof=atomic best_effort"`
+	Add    []attendeeRef `json:"add" binding:"dive"`
+	Remove []attendeeRef `json:"remove" binding:"dive"`
+}
+
+type batchAttendeesResponse struct {
+	Mode    string                          `json:"mode"`
+	Applied bool                            `json:"applied"`
+	Results []database.AttendeeChangeResult `json:"results"`
+}
+
+// batchUpdateAttendees godoc
+//
+//	@Summary		Add and remove many attendees
+//	@Description	Add and remove up to 500 attendees, identified by user ID or email, in one transaction (requires authentication and ownership).
+//	@Description	In "atomic" mode nothing is applied unless every change succeeds; in "best_effort" mode every change that can be applied is applied.
+//	@Description	Each change is reported as added, removed, user_not_found, already_attending, not_attending or rolled_back.
+//	@Tags			attendees
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Event ID"
+//	@Param			batch	body		batchAttendeesRequest	true	"Attendees to add and remove"
+//	@Success		200		{object}	batchAttendeesResponse
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		403		{object}	problem
+//	@Failure		404		{object}	problem
+//	@Failure		422		{object}	batchAttendeesResponse
+//	@Failure		500		{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/attendees/batch [post]
+func (app *application) batchUpdateAttendees(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	var request batchAttendeesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	total := len(request.Add) + len(request.Remove)
+	if total == 0 || total > maxBatchAttendees {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidBatchSize, "A batch must contain between 1 and 500 changes")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to manage this event's attendees")
+		return
+	}
+
+	changes := make([]database.AttendeeChange, 0, total)
+	for _, ref := range request.Add {
+		changes = append(changes, database.AttendeeChange{Action: database.AttendeeActionAdd, UserId: ref.UserId, Email: ref.Email})
+	}
+	for _, ref := range request.Remove {
+		changes = append(changes, database.AttendeeChange{Action: database.AttendeeActionRemove, UserId: ref.UserId, Email: ref.Email})
+	}
+
+	// The users added and removed are notified in the same transaction as the changes.
+	var results []database.AttendeeChangeResult
+	var applied bool
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		results, applied, err = app.models.Attendees.ApplyChanges(ctx, event.Id, changes, request.Mode == batchModeAtomic)
+		if err != nil || !applied {
+			return err
+		}
+
+		for _, result := range results {
+			switch result.Status {
+			case database.AttendeeStatusAdded:
+				err = app.notifyAttendeeChange(ctx, event, result.UserId, user.Id, database.NotificationAttendeeAdded)
+			case database.AttendeeStatusRemoved:
+				err = app.notifyAttendeeChange(ctx, event, result.UserId, user.Id, database.NotificationAttendeeRemoved)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if applied {
+		for _, result := range results {
+			if result.Status == database.AttendeeStatusAdded {
+				app.metrics.attendeesAdded.Inc()
+			}
+		}
+	} else {
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.JSON(status, batchAttendeesResponse{
+		Mode:    request.Mode,
+		Applied: applied,
+		Results: results,
+	})
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"go-event-crud/internal/database"
+	"go-event-crud/internal/mail"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,20 +31,34 @@ type registerRequest struct {
 //	@Produce		json
 //	@Param			user	body		registerRequest	true	"User registration data"
 //	@Success		201		{object}	database.User
-//	@Failure		400		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
+//	@Failure		400		{object}	problem
+//	@Failure		409		{object}	problem
+//	@Failure		500		{object}	problem
 //	@Router			/register [post]
 func (app *application) registerUser(c *gin.Context) {
 	var register registerRequest
 	if err := c.ShouldBindJSON(&register); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	existingUser, err := app.models.Users.GetByEmail(c.Request.Context(), register.Email)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if existingUser != nil {
+		app.errorResponse(c, http.StatusConflict, codeEmailTaken, "A user with this email already exists")
 		return
 	}
 
 	// Hash the password
+	_, span := tracer.Start(c.Request.Context(), "bcrypt.GenerateFromPassword")
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(register.Password), bcrypt.DefaultCost)
+	span.End()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 	register.Password = string(hashedPassword)
@@ -47,9 +67,9 @@ func (app *application) registerUser(c *gin.Context) {
 		Password: register.Password,
 		Name:     register.Name,
 	}
-	err = app.models.Users.Insert(&user)
+	err = app.models.Users.Insert(c.Request.Context(), &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		app.serverErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, user)
@@ -73,40 +93,168 @@ type loginResponse struct {
 //	@Produce		json
 //	@Param			credentials	body		loginRequest	true	"User login credentials"
 //	@Success		200			{object}	loginResponse
-//	@Failure		400			{object}	map[string]string
-//	@Failure		401			{object}	map[string]string
-//	@Failure		404			{object}	map[string]string
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		500			{object}	problem
 //	@Router			/login [post]
 func (app *application) login(c *gin.Context) {
 
 	var auth loginRequest
 	if err := c.ShouldBindJSON(&auth); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		app.validationErrorResponse(c, err)
 		return
 	}
 
-	existingUser, err := app.models.Users.GetByEmail(auth.Email)
+	existingUser, err := app.models.Users.GetByEmail(c.Request.Context(), auth.Email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
+	if existingUser == nil {
+		app.metrics.failedLogins.WithLabelValues("unknown_email").Inc()
+		app.errorResponse(c, http.StatusNotFound, codeUserNotFound, "User not found")
+		return
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(auth.Password))
+	span.End()
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		app.metrics.failedLogins.WithLabelValues("wrong_password").Inc()
+		app.errorResponse(c, http.StatusUnauthorized, codeInvalidCredentials, "Invalid password")
 		return
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId": existingUser.Id,
-		"exp":    time.Now().Add(time.Hour * 72).Unix(), // Token expires in 72 hours
+		"exp":    time.Now().Add(app.config.Auth.TokenLifetime).Unix(),
 	})
 
-	tokenString, err := token.SignedString([]byte(app.jwtSecret))
+	tokenString, err := token.SignedString([]byte(app.config.Auth.JWTSecret))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, loginResponse{Token: tokenString})
 }
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// requestPasswordReset godoc
+//
+//	@Summary		Request a password reset
+//	@Description	Email a link to reset the password to the user with this email address, if there is one.
+//	@Description	The response is the same either way, so it does not reveal which addresses have accounts.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	passwordResetRequest	true	"Email address of the account"
+//	@Success		202		"Accepted"
+//	@Failure		400		{object}	problem
+//	@Failure		500		{object}	problem
+//	@Router			/password-reset [post]
+func (app *application) requestPasswordReset(c *gin.Context) {
+	var request passwordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(c.Request.Context(), request.Email)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if user != nil && app.config.Mail.Enabled() {
+		token := newPasswordResetToken()
+		ttl := app.config.Auth.PasswordResetTTL
+
+		err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+			if err := app.models.PasswordResets.Insert(ctx, user.Id, hashPasswordResetToken(token), time.Now().Add(ttl)); err != nil {
+				return err
+			}
+
+			return app.queueEmail(ctx, user, "", mail.TemplatePasswordReset, map[string]any{
+				"ResetURL":         app.config.Mail.PasswordResetURL + "?" + url.Values{"token": {token}}.Encode(),
+				"ExpiresInMinutes": int(ttl.Minutes()),
+			})
+		})
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// confirmPasswordReset godoc
+//
+//	@Summary		Reset a password
+//	@Description	Set a new password with the token from a password reset email. A token can only be used once, and using it invalidates the user's other reset tokens.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	passwordResetConfirmRequest	true	"Reset token and new password"
+//	@Success		204		"No Content"
+//	@Failure		400		{object}	problem
+//	@Failure		500		{object}	problem
+//	@Router			/password-reset/confirm [post]
+func (app *application) confirmPasswordReset(c *gin.Context) {
+	var request passwordResetConfirmRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "bcrypt.GenerateFromPassword")
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	span.End()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		userId, err := app.models.PasswordResets.Consume(ctx, hashPasswordResetToken(request.Token), time.Now())
+		if err != nil {
+			return err
+		}
+
+		if userId == 0 {
+			return &apiError{Status: http.StatusBadRequest, Code: codeInvalidResetToken, Detail: "Password reset token is invalid or has expired"}
+		}
+
+		return app.models.Users.UpdatePassword(ctx, userId, string(hashedPassword))
+	})
+	if err != nil {
+		app.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// newPasswordResetToken returns a random token for a password reset link.
+func newPasswordResetToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hashPasswordResetToken returns the hash a password reset token is stored under.
+func hashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"go-event-crud/internal/database"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type emailPreferencesRequest struct {
+	Locale        string `json:"locale" binding:"required"`
+	Invitations   *bool  `json:"invitations" binding:"required"`
+	Reminders     *bool  `json:"reminders" binding:"required"`
+	Cancellations *bool  `json:"cancellations" binding:"required"`
+}
+
+type unsubscribeQuery struct {
+	Token    string `form:"token" binding:"required"`
+	Category string `form:"category"`
+}
+
+// getEmailPreferences godoc
+//
+//	@Summary		Get email preferences
+//	@Description	Get the locale the authenticated user's emails are written in and the kinds of email they receive
+//	@Tags			email
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	database.EmailPreferences
+//	@Failure		401	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/email-preferences [get]
+func (app *application) getEmailPreferences(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	prefs, err := app.models.EmailPreferences.Get(c.Request.Context(), user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// updateEmailPreferences godoc
+//
+//	@Summary		Update email preferences
+//	@Description	Set the locale the authenticated user's emails are written in and the kinds of email they receive.
+//	@Description	Password reset emails are always sent.
+//	@Tags			email
+//	@Accept			json
+//	@Produce		json
+//	@Param			preferences	body		emailPreferencesRequest	true	"Email preferences"
+//	@Success		200			{object}	database.EmailPreferences
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/email-preferences [put]
+func (app *application) updateEmailPreferences(c *gin.Context) {
+	var request emailPreferencesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	if !app.mailTemplates.HasLocale(request.Locale) {
+		locales := strings.Join(app.mailTemplates.Locales(), ", ")
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidLocale, fmt.Sprintf("Locale must be one of: %s", locales))
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	prefs, err := app.models.EmailPreferences.Get(c.Request.Context(), user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	prefs.Locale = request.Locale
+	prefs.Invitations = *request.Invitations
+	prefs.Reminders = *request.Reminders
+	prefs.Cancellations = *request.Cancellations
+
+	if err := app.models.EmailPreferences.Update(c.Request.Context(), prefs); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// unsubscribePage is the page an unsubscribe link opens. It only asks for confirmation,
+// since mail scanners follow links in emails; the form POSTs back to the same link.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>{{if .Category}}Stop sending you {{.Category}} emails?{{else}}Stop sending you any emails?{{end}}</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmUnsubscribe godoc
+//
+//	@Summary		Confirm unsubscribing from emails
+//	@Description	Show a page that asks to turn off one kind of email, or every kind if no category is given. This is the link at
+//	@Description	the bottom of emails. It changes nothing, since mail scanners open links; its form POSTs to /unsubscribe.
+//	@Tags			email
+//	@Produce		html
+//	@Param			token		query		string	true	"Unsubscribe token from the link"
+//	@Param			category	query		string	false	"Kind of email: invitations, reminders or cancellations"
+//	@Success		200			"Confirmation page"
+//	@Failure		400			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Router			/unsubscribe [get]
+func (app *application) confirmUnsubscribe(c *gin.Context) {
+	query, prefs, ok := app.readUnsubscribeLink(c)
+	if !ok {
+		return
+	}
+
+	// checked on a copy, so an unknown category is reported before the form is shown
+	check := *prefs
+	if !check.Unsubscribe(query.Category) {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEmailCategory, "Category must be invitations, reminders or cancellations")
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	err := unsubscribePage.Execute(c.Writer, map[string]string{
+		"Action":   c.Request.URL.RequestURI(),
+		"Category": query.Category,
+	})
+	if err != nil {
+		requestLogger(c).Error("failed to render unsubscribe page", "error", err)
+	}
+}
+
+// unsubscribe godoc
+//
+//	@Summary		Unsubscribe from emails
+//	@Description	Turn off one kind of email, or every kind if no category is given, for the user an unsubscribe link was sent to.
+//	@Description	Mail clients POST to the link for one-click unsubscribing (RFC 8058), as does the confirmation page. No authentication is needed.
+//	@Tags			email
+//	@Accept			json
+//	@Produce		json
+//	@Param			token		query		string	true	"Unsubscribe token from the link"
+//	@Param			category	query		string	false	"Kind of email: invitations, reminders or cancellations"
+//	@Success		200			{object}	database.EmailPreferences
+//	@Failure		400			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Router			/unsubscribe [post]
+func (app *application) unsubscribe(c *gin.Context) {
+	query, prefs, ok := app.readUnsubscribeLink(c)
+	if !ok {
+		return
+	}
+
+	if !prefs.Unsubscribe(query.Category) {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEmailCategory, "Category must be invitations, reminders or cancellations")
+		return
+	}
+
+	if err := app.models.EmailPreferences.Update(c.Request.Context(), prefs); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// readUnsubscribeLink reads the token and category of an unsubscribe link and looks up
+// the preferences the token belongs to. It writes an error response and reports false if
+// the link is not valid.
+func (app *application) readUnsubscribeLink(c *gin.Context) (unsubscribeQuery, *database.EmailPreferences, bool) {
+	var query unsubscribeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		app.validationErrorResponse(c, err)
+		return query, nil, false
+	}
+
+	prefs, err := app.models.EmailPreferences.GetByToken(c.Request.Context(), query.Token)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return query, nil, false
+	}
+
+	if prefs == nil {
+		app.errorResponse(c, http.StatusNotFound, codeUnsubscribeNotFound, "Unsubscribe link is not valid")
+		return query, nil, false
+	}
+
+	return query, prefs, true
+}
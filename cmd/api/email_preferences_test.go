@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeLinkOnlyChangesOnPost(t *testing.T) {
+	ts := newTestServer(t)
+	user, _ := ts.signUp(t, "guest")
+
+	prefs, err := ts.app.models.EmailPreferences.Get(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("get preferences: %v", err)
+	}
+	link := "/api/v1/unsubscribe?category=reminders&token=" + prefs.UnsubscribeToken
+
+	// a mail scanner following the link
+	rec := ts.request(t, http.MethodGet, link, "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post"`) {
+		t.Fatalf("GET link: status %d: %s; want a confirmation form", rec.Code, rec.Body)
+	}
+
+	prefs, err = ts.app.models.EmailPreferences.Get(context.Background(), user.Id)
+	if err != nil || !prefs.Reminders {
+		t.Fatalf("preferences after GET = %+v, %v; want reminders still on", prefs, err)
+	}
+
+	rec = ts.request(t, http.MethodPost, link, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST link: status %d: %s", rec.Code, rec.Body)
+	}
+
+	prefs, err = ts.app.models.EmailPreferences.Get(context.Background(), user.Id)
+	if err != nil || prefs.Reminders || !prefs.Invitations {
+		t.Fatalf("preferences after POST = %+v, %v; want only reminders off", prefs, err)
+	}
+
+	rec = ts.request(t, http.MethodGet, "/api/v1/unsubscribe?category=spam&token="+prefs.UnsubscribeToken, "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET link with an unknown category: status %d, want 400", rec.Code)
+	}
+}
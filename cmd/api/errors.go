@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const problemContentType = "application/problem+json"
+
+// Error codes are part of the API contract: clients match on them, so existing codes
+// must never be renamed or reused for a different condition.
+const (
+	codeValidationFailed      = "validation_failed"
+	codeMalformedRequest      = "malformed_request"
+	codeInvalidEventId        = "invalid_event_id"
+	codeInvalidUserId         = "invalid_user_id"
+	codeInvalidAttendeeId     = "invalid_attendee_id"
+	codeInvalidRevision       = "invalid_revision"
+	codeInvalidNotificationId = "invalid_notification_id"
+	codeInvalidJobId          = "invalid_job_id"
+	codeInvalidPatch          = "invalid_patch"
+	codeInvalidStatus         = "invalid_status"
+	codeInvalidTransition     = "invalid_transition"
+	codeInvalidBatchSize      = "invalid_batch_size"
+	codeInvalidLocale         = "invalid_locale"
+	codeInvalidEmailCategory  = "invalid_email_category"
+	codeInvalidResetToken     = "invalid_reset_token"
+	codeReasonRequired        = "reason_required"
+	codeDateNotAllowed        = "date_not_allowed"
+	codeEventNotFound         = "event_not_found"
+	codeUserNotFound          = "user_not_found"
+	codeRevisionNotFound      = "revision_not_found"
+	codeNotificationNotFound  = "notification_not_found"
+	codeJobNotFound           = "job_not_found"
+	codeJobNotFailed          = "job_not_failed"
+	codeUnsubscribeNotFound   = "unsubscribe_token_not_found"
+	codeRouteNotFound         = "route_not_found"
+	codeMethodNotAllowed      = "method_not_allowed"
+	codeMissingAuthorization  = "missing_authorization"
+	codeInvalidToken          = "invalid_token"
+	codeInvalidCredentials    = "invalid_credentials"
+	codeNotEventOwner         = "not_event_owner"
+	codeAdminRequired         = "admin_required"
+	codeEmailTaken            = "email_taken"
+	codeAttendeeExists        = "attendee_exists"
+	codeAttendeeNotFound      = "attendee_not_found"
+	codeEventNotDraft         = "event_not_draft"
+	codeEditConflict          = "edit_conflict"
+	codePreconditionFailed    = "precondition_failed"
+	codePreconditionRequired  = "precondition_required"
+	codeUnsupportedMediaType  = "unsupported_media_type"
+	codeIdempotencyKeyInvalid = "idempotency_key_invalid"
+	codeIdempotencyKeyInUse   = "idempotency_key_in_use"
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	codeBackupInProgress      = "backup_in_progress"
+	codeBackupUnsupported     = "backup_unsupported"
+	codeRateLimited           = "rate_limited"
+	codeInternalError         = "internal_error"
+)
+
+// problem is an RFC 7807 problem details document, extended with a stable error code,
+// per-field validation errors and, for server errors, a correlation ID.
+type problem struct {
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Status        int          `json:"status"`
+	Detail        string       `json:"detail,omitempty"`
+	Instance      string       `json:"instance,omitempty"`
+	Code          string       `json:"code"`
+	Errors        []fieldError `json:"errors,omitempty"`
+	CorrelationId string       `json:"correlationId,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiError is the error every handler reports through writeError. Err holds the cause of
+// a server error; it is logged but never sent to the client.
+type apiError struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []fieldError
+	Err    error
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+// writeError renders an apiError as application/problem+json and aborts the request.
+// Server errors are logged under a fresh correlation ID and their cause is hidden.
+func (app *application) writeError(c *gin.Context, e *apiError) {
+	p := problem{
+		Type:     "urn:go-event-crud:problem:" + e.Code,
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Instance: c.Request.URL.Path,
+		Code:     e.Code,
+		Errors:   e.Fields,
+	}
+
+	if e.Status >= http.StatusInternalServerError {
+		p.CorrelationId = newCorrelationId()
+		p.Detail = "An internal error occurred. Quote the correlation ID when reporting this problem."
+		c.Header("X-Correlation-ID", p.CorrelationId)
+		requestLogger(c).Error("server error",
+			"correlation_id", p.CorrelationId,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"error", e.Error(),
+		)
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(e.Status, p)
+}
+
+// errorResponse reports a client error with a stable code and a human-readable detail.
+func (app *application) errorResponse(c *gin.Context, status int, code, detail string) {
+	app.writeError(c, &apiError{Status: status, Code: code, Detail: detail})
+}
+
+// serverErrorResponse reports an unexpected failure without exposing its cause.
+func (app *application) serverErrorResponse(c *gin.Context, err error) {
+	app.writeError(c, &apiError{Status: http.StatusInternalServerError, Code: codeInternalError, Err: err})
+}
+
+// handleError reports an error returned from a unit of work: an *apiError is sent as is
+// and anything else is treated as a server error.
+func (app *application) handleError(c *gin.Context, err error) {
+	var e *apiError
+	if errors.As(err, &e) {
+		app.writeError(c, e)
+		return
+	}
+	app.serverErrorResponse(c, err)
+}
+
+// validationErrorResponse reports a request body that could not be bound, translating
+// validator errors into one entry per invalid field.
+func (app *application) validationErrorResponse(c *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		fields := make([]fieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			fields = append(fields, fieldError{
+				Field:   fieldPath(fe),
+				Code:    fe.Tag(),
+				Message: validationMessage(fe),
+			})
+		}
+		app.writeError(c, &apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: "The request body contains invalid fields",
+			Fields: fields,
+		})
+	case errors.As(err, &typeError):
+		app.writeError(c, &apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: "The request body contains invalid fields",
+			Fields: []fieldError{{
+				Field:   typeError.Field,
+				Code:    "type",
+				Message: fmt.Sprintf("must be of type %s", typeError.Type),
+			}},
+		})
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		app.errorResponse(c, http.StatusBadRequest, codeMalformedRequest, "The request body is not valid JSON")
+	default:
+		app.errorResponse(c, http.StatusBadRequest, codeMalformedRequest, "The request body could not be read")
+	}
+}
+
+// fieldPath returns the JSON path of an invalid field without the root struct name,
+// for example "add[0].email".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", fe.Param())
+	case "excluded_with":
+		return fmt.Sprintf("must not be set together with %s", fe.Param())
+	case "min":
+		if fe.Kind() != reflect.String {
+			return fmt.Sprintf("must be at least %s", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	case "max":
+		if fe.Kind() != reflect.String {
+			return fmt.Sprintf("must be at most %s", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "email":
+		return "must be a valid email address"
+	case "datetime":
+		return fmt.Sprintf("must be a date in the format %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	default:
+		return "is invalid"
+	}
+}
+
+// useJSONFieldNames makes validator errors name fields by their JSON keys, or by their
+// query parameters for query structs.
+func useJSONFieldNames() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name, _, _ = strings.Cut(field.Tag.Get("form"), ",")
+		}
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+func newCorrelationId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recoverPanic turns a panic in a handler into a problem response.
+func (app *application) recoverPanic(c *gin.Context, recovered any) {
+	app.serverErrorResponse(c, fmt.Errorf("panic: %v", recovered))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"go-event-crud/internal/database"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventETag identifies a version of an event. Every write bumps the version, so the
+// ETag changes whenever the stored event does.
+func eventETag(event *database.Event) string {
+	return fmt.Sprintf(`"%d"`, event.Version)
+}
+
+// errPreconditionFailed reports a write that lost to a concurrent one after its If-Match
+// check passed.
+var errPreconditionFailed = &apiError{Status: http.StatusPreconditionFailed, Code: codePreconditionFailed, Detail: "Event has been modified"}
+
+// etagMatches reports whether an If-Match or If-None-Match header value lists the ETag.
+// If-Match needs the strong comparison of RFC 7232, under which a weak validator never
+// matches; If-None-Match uses the weak comparison, which ignores the W/ prefix.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition on a write to an event. It writes a
+// 428 if the header is missing or a 412 if it does not match, and reports whether the
+// handler may continue.
+func (app *application) checkIfMatch(c *gin.Context, event *database.Event) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		app.errorResponse(c, http.StatusPreconditionRequired, codePreconditionRequired, "If-Match header is required")
+		return false
+	}
+
+	if !etagMatches(ifMatch, eventETag(event), false) {
+		c.Header("ETag", eventETag(event))
+		app.errorResponse(c, http.StatusPreconditionFailed, codePreconditionFailed, "Event has been modified")
+		return false
+	}
+
+	return true
+}
+
+// checkIfNoneMatch sets the ETag of an event and answers 304 Not Modified when the
+// client already has this version. It reports whether the handler should write a body.
+func checkIfNoneMatch(c *gin.Context, event *database.Event) bool {
+	etag := eventETag(event)
+	c.Header("ETag", etag)
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		c.Status(http.StatusNotModified)
+		return false
+	}
+
+	return true
+}
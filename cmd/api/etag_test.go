@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"2", "3"`, false, true},
+		{`*`, false, true},
+		{`"2"`, false, false},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`"2", W/"3"`, true, true},
+		{`W/"2"`, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"3"`, tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%s, weak %v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
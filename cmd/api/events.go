@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go-event-crud/internal/database"
 	"net/http"
@@ -12,33 +14,59 @@ import (
 // createEvent godoc
 //
 //	@Summary		Create a new event
-//	@Description	Create a new event (requires authentication)
+//	@Description	Create a new event (requires authentication). Events are published immediately unless status is "draft"
+//	@Description	or a future publishAt is given, in which case they stay visible only to the owner until published.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
 //	@Param			event	body		database.Event	true	"Event data"
 //	@Success		201		{object}	database.Event
-//	@Failure		400		{object}	map[string]string
-//	@Failure		401		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		500		{object}	problem
 //	@Security		BearerAuth
 //	@Router			/events [post]
 func (app *application) createEvent(c *gin.Context) {
 	var event database.Event
 	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		app.validationErrorResponse(c, err)
 		return
 	}
 
 	user := app.GetUserFromContext(c)
 	event.OwnerId = user.Id
 
-	err := app.models.Events.Insert(&event)
+	switch {
+	case event.Status == database.EventStatusDraft || event.PublishAt != nil:
+		event.Status = database.EventStatusDraft
+	case event.Status == "" || event.Status == database.EventStatusPublished:
+		event.Status = database.EventStatusPublished
+	default:
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidStatus, "New events must be draft or published")
+		return
+	}
+
+	// The event and its first revision are written together, so a failure leaves neither
+	// behind.
+	err := app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		if err := app.models.Events.Insert(ctx, &event); err != nil {
+			return err
+		}
+
+		return app.recordEventRevision(ctx, nil, &event, user.Id)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
+	if event.PublishAt != nil {
+		app.wakePublisher()
+	}
+
+	c.Header("ETag", eventETag(&event))
+	app.metrics.eventsCreated.Inc()
+
 	c.JSON(http.StatusCreated, event)
 }
 
@@ -49,32 +77,39 @@ func (app *application) createEvent(c *gin.Context) {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Event ID"
-//	@Success		200	{object}	database.Event
-//	@Failure		400	{object}	map[string]string
-//	@Failure		404	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
+//	@Param			id				path		int		true	"Event ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy of the event"
+//	@Success		200				{object}	database.Event
+//	@Success		304				"Not Modified"
+//	@Failure		400				{object}	problem
+//	@Failure		404				{object}	problem
+//	@Failure		500				{object}	problem
 //	@Router			/events/{id} [get]
 func (app *application) getEventById(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	// Return a 400 Bad Request if the ID is not a valid integer
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
 		return
 	}
 
-	event, err := app.models.Events.GetById(id)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
 
-	// Return a 404 Not Found if the event does not exist
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	// Return a 500 Internal Server Error if there was an error retrieving the event
+	if err != nil {
+		app.serverErrorResponse(c, err)
 		return
 	}
 
-	// Return a 500 Internal Server Error if there was an error retrieving the event
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve event: %s", err.Error())})
+	// Return a 404 Not Found if the event does not exist or is a draft the user may not see
+	if event == nil || !event.VisibleTo(app.GetUserFromContext(c).Id) {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	// Return a 304 Not Modified if the client already has this version
+	if !checkIfNoneMatch(c, event) {
 		return
 	}
 
@@ -84,18 +119,19 @@ func (app *application) getEventById(c *gin.Context) {
 // getAllEvents godoc
 //
 //	@Summary		Get all events
-//	@Description	Get a list of all events
+//	@Description	Get a list of all events, including the caller's own drafts when authenticated
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
 //	@Success		200	{array}		database.Event
-//	@Failure		500	{object}	map[string]string
+//	@Failure		500	{object}	problem
 //	@Router			/events [get]
 func (app *application) getAllEvents(c *gin.Context) {
-	allEvents, err := app.models.Events.GetAll()
+	user := app.GetUserFromContext(c)
+	allEvents, err := app.models.Events.GetAll(c.Request.Context(), user.Id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve events: %s", err.Error())})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
@@ -109,38 +145,45 @@ func (app *application) getAllEvents(c *gin.Context) {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int				true	"Event ID"
-//	@Param			event	body		database.Event	true	"Updated event data"
-//	@Success		200		{object}	database.Event
-//	@Failure		400		{object}	map[string]string
-//	@Failure		401		{object}	map[string]string
-//	@Failure		403		{object}	map[string]string
-//	@Failure		404		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			If-Match	header		string			true	"ETag of the event being updated"
+//	@Param			event		body		database.Event	true	"Updated event data"
+//	@Success		200			{object}	database.Event
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		412			{object}	problem
+//	@Failure		428			{object}	problem
+//	@Failure		500			{object}	problem
 //	@Security		BearerAuth
 //	@Router			/events/{id} [put]
 func (app *application) updateEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
 		return
 	}
 
 	user := app.GetUserFromContext(c)
-	existingEvent, err := app.models.Events.GetById(id)
+	existingEvent, err := app.models.Events.GetById(c.Request.Context(), id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
 	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
 		return
 	}
 
 	if existingEvent.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to update this event"})
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to update this event")
+		return
+	}
+
+	if !app.checkIfMatch(c, existingEvent) {
 		return
 	}
 
@@ -149,59 +192,103 @@ func (app *application) updateEvent(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&updateEvent); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		app.validationErrorResponse(c, err)
 		return
 	}
 
-	if err := app.models.Events.Update(updateEvent); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+	updateEvent.Status = existingEvent.Status
+	updateEvent.StatusReason = existingEvent.StatusReason
+	updateEvent.StatusChangedAt = existingEvent.StatusChangedAt
+	updateEvent.PublishAt = existingEvent.PublishAt
+
+	// The update, its revision and the notifications commit together, so a failure part
+	// way through leaves the event as it was and the request can be retried.
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		updateEvent.Version = existingEvent.Version
+
+		if err := app.models.Events.Update(ctx, updateEvent); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				return errPreconditionFailed
+			}
+			return err
+		}
+
+		if err := app.recordEventRevision(ctx, existingEvent, updateEvent, user.Id); err != nil {
+			return err
+		}
+
+		return app.notifyEventUpdated(ctx, existingEvent, updateEvent)
+	})
+	if err != nil {
+		app.handleError(c, err)
 		return
 	}
 
+	c.Header("ETag", eventETag(updateEvent))
 	c.JSON(http.StatusOK, updateEvent)
 }
 
 // deleteEvent godoc
 //
 //	@Summary		Delete an event
-//	@Description	Delete an existing event (requires authentication and ownership)
+//	@Description	Move an existing event to the trash (requires authentication and ownership)
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path	int	true	"Event ID"
-//	@Success		204	"No Content"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		401	{object}	map[string]string
-//	@Failure		404	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
+//	@Param			id			path	int		true	"Event ID"
+//	@Param			If-Match	header	string	true	"ETag of the event being deleted"
+//	@Success		204			"No Content"
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		412			{object}	problem
+//	@Failure		428			{object}	problem
+//	@Failure		500			{object}	problem
 //	@Security		BearerAuth
 //	@Router			/events/{id} [delete]
 func (app *application) deleteEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
 		return
 	}
 
 	user := app.GetUserFromContext(c)
-	existingEvent, err := app.models.Events.GetById(id)
+	existingEvent, err := app.models.Events.GetById(c.Request.Context(), id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
 		return
 	}
 
 	if user.Id != existingEvent.OwnerId {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to delete this event")
+		return
+	}
+
+	if !app.checkIfMatch(c, existingEvent) {
 		return
 	}
 
-	if err := app.models.Events.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		if err := app.models.Events.Delete(ctx, id, existingEvent.Version); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				return errPreconditionFailed
+			}
+			return err
+		}
+
+		message := fmt.Sprintf("%q has been deleted", existingEvent.Name)
+		_, err := app.models.Notifications.InsertForAttendees(ctx, id, database.NotificationEventDeleted, message)
+		return err
+	})
+	if err != nil {
+		app.handleError(c, err)
 		return
 	}
 
@@ -218,76 +305,80 @@ func (app *application) deleteEvent(c *gin.Context) {
 //	@Param			id		path	int	true	"Event ID"
 //	@Param			userId	path	int	true	"User ID"
 //	@Success		201		"Created"
-//	@Failure		400		{object}	map[string]string
-//	@Failure		401		{object}	map[string]string
-//	@Failure		404		{object}	map[string]string
-//	@Failure		409		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		403		{object}	problem
+//	@Failure		404		{object}	problem
+//	@Failure		409		{object}	problem
+//	@Failure		500		{object}	problem
 //	@Security		BearerAuth
 //	@Router			/events/{id}/attendees/{userId} [post]
 func (app *application) addAttendeeToEvent(c *gin.Context) {
 	eventId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
 		return
 	}
 
 	userId, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	event, err := app.models.Events.GetById(eventId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
-		return
-	}
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		return
-	}
-
-	userToAdd, err := app.models.Users.GetById(userId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidUserId, "Invalid user ID")
 		return
 	}
 
 	user := app.GetUserFromContext(c)
-
-	if user.Id != event.OwnerId {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
-		return
-	}
-
-	if userToAdd == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	existingAttendee, err := app.models.Attendees.GetByEventAndAttendee(event.Id, userToAdd.Id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendee"})
-		return
-	}
-	if existingAttendee != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Attendee already exists"})
-		return
-	}
-
 	attendee := database.Attendee{
-		EventId: event.Id,
-		UserId:  userToAdd.Id,
-	}
-
-	_, err = app.models.Attendees.Insert(&attendee)
+		EventId: eventId,
+		UserId:  userId,
+	}
+
+	// The checks and the insert run in one transaction, so a concurrent request cannot
+	// delete the event or add the same attendee in between.
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		event, err := app.models.Events.GetById(ctx, eventId)
+		if err != nil {
+			return err
+		}
+
+		if event == nil {
+			return &apiError{Status: http.StatusNotFound, Code: codeEventNotFound, Detail: "Event not found"}
+		}
+
+		if user.Id != event.OwnerId {
+			return &apiError{Status: http.StatusForbidden, Code: codeNotEventOwner, Detail: "You are not authorized to manage this event's attendees"}
+		}
+
+		userToAdd, err := app.models.Users.GetById(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		if userToAdd == nil {
+			return &apiError{Status: http.StatusNotFound, Code: codeUserNotFound, Detail: "User not found"}
+		}
+
+		existingAttendee, err := app.models.Attendees.GetByEventAndAttendee(ctx, eventId, userId)
+		if err != nil {
+			return err
+		}
+
+		if existingAttendee != nil {
+			return &apiError{Status: http.StatusConflict, Code: codeAttendeeExists, Detail: "Attendee already exists"}
+		}
+
+		if _, err := app.models.Attendees.Insert(ctx, &attendee); err != nil {
+			return err
+		}
+
+		return app.notifyAttendeeChange(ctx, event, userId, user.Id, database.NotificationAttendeeAdded)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
+		app.handleError(c, err)
 		return
 	}
 
+	app.metrics.attendeesAdded.Inc()
+
 	c.JSON(http.StatusCreated, attendee)
 }
 
@@ -300,19 +391,31 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path		int	true	"Event ID"
 //	@Success		200	{array}		database.User
-//	@Failure		400	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
+//	@Failure		400	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
 //	@Router			/events/{id}/attendees [get]
 func (app *application) getAttendeesForEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
 		return
 	}
 
-	users, err := app.models.Attendees.GetAttendeesByEvent(id)
+	if event == nil || !event.VisibleTo(app.GetUserFromContext(c).Id) {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	users, err := app.models.Attendees.GetAttendeesByEvent(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		app.serverErrorResponse(c, err)
 		return
 	}
 
@@ -329,40 +432,63 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 //	@Param			id		path	int	true	"Event ID"
 //	@Param			userId	path	int	true	"User ID"
 //	@Success		204		"No Content"
-//	@Failure		400		{object}	map[string]string
-//	@Failure		401		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		403		{object}	problem
+//	@Failure		404		{object}	problem
+//	@Failure		500		{object}	problem
 //	@Security		BearerAuth
 //	@Router			/events/{id}/attendees/{userId} [delete]
 func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
 		return
 	}
 
 	userId, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidUserId, "Invalid user ID")
 		return
 	}
 
-	event, err := app.models.Events.GetById(id)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
 		return
 	}
 
 	user := app.GetUserFromContext(c)
 
 	if user.Id != event.OwnerId {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to manage this event's attendees")
 		return
 	}
 
-	err = app.models.Attendees.Delete(userId, id)
+	// Only a user who was attending is told they were removed; the lookup, the delete and
+	// the notification run in one transaction so they agree.
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		attendee, err := app.models.Attendees.GetByEventAndAttendee(ctx, id, userId)
+		if err != nil {
+			return err
+		}
+
+		if err := app.models.Attendees.Delete(ctx, userId, id); err != nil {
+			return err
+		}
+
+		if attendee == nil {
+			return nil
+		}
+		return app.notifyAttendeeChange(ctx, event, userId, user.Id, database.NotificationAttendeeRemoved)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendee"})
+		app.serverErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusNoContent, nil)
@@ -377,19 +503,19 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path		int	true	"Attendee (User) ID"
 //	@Success		200	{array}		database.Event
-//	@Failure		400	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
+//	@Failure		400	{object}	problem
+//	@Failure		500	{object}	problem
 //	@Router			/attendees/{id}/events [get]
 func (app *application) getEventsByAttendee(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attendee ID"})
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidAttendeeId, "Invalid attendee ID")
 		return
 	}
 
-	events, err := app.models.Events.GetByAttendee(id)
+	events, err := app.models.Events.GetByAttendee(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		app.serverErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, events)
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go-event-crud/internal/database"
+)
+
+func TestCreateEventRecordsRevision(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+
+	rec := ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create event: status %d: %s", rec.Code, rec.Body)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("ETag = %s, want \"1\"", etag)
+	}
+
+	var event database.Event
+	decode(t, rec, &event)
+
+	rec = ts.request(t, http.MethodGet, fmt.Sprintf("/api/v1/events/%d/revisions", event.Id), token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get revisions: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var revisions []*database.EventRevision
+	decode(t, rec, &revisions)
+	if len(revisions) != 1 || revisions[0].Name != "Party time" {
+		t.Fatalf("revisions = %+v, want the created event", revisions)
+	}
+}
+
+func TestUpdateEventNotifiesAttendees(t *testing.T) {
+	ts := newTestServer(t)
+	_, ownerToken := ts.signUp(t, "owner")
+	guest, guestToken := ts.signUp(t, "guest")
+
+	event := ts.createEvent(t, ownerToken)
+	ts.addAttendee(t, ownerToken, event.Id, guest.Id)
+
+	body := newEventBody()
+	body["location"] = "Somewhere else"
+
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+	rec := ts.request(t, http.MethodPut, path, ownerToken, body, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update event: status %d: %s", rec.Code, rec.Body)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf("ETag = %s, want \"2\"", etag)
+	}
+
+	if n := countType(ts.notifications(t, guestToken), database.NotificationEventUpdated); n != 1 {
+		t.Fatalf("got %d event_updated notifications, want 1", n)
+	}
+
+	rec = ts.request(t, http.MethodPut, path, ownerToken, body, "If-Match", `"1"`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("update with a stale ETag: status %d, want 412", rec.Code)
+	}
+}
+
+func TestUpdateEventWithoutChangesDoesNotNotify(t *testing.T) {
+	ts := newTestServer(t)
+	_, ownerToken := ts.signUp(t, "owner")
+	guest, guestToken := ts.signUp(t, "guest")
+
+	event := ts.createEvent(t, ownerToken)
+	ts.addAttendee(t, ownerToken, event.Id, guest.Id)
+
+	// The SQL stores read dates back as timestamps, while clients send plain dates.
+	event.Date = "2030-01-01T00:00:00Z"
+	if err := ts.app.models.Events.Update(context.Background(), event); err != nil {
+		t.Fatalf("update stored date: %v", err)
+	}
+
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+	rec := ts.request(t, http.MethodPut, path, ownerToken, newEventBody(), "If-Match", eventETag(event))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	if n := countType(ts.notifications(t, guestToken), database.NotificationEventUpdated); n != 0 {
+		t.Fatalf("got %d event_updated notifications for an unchanged event, want 0", n)
+	}
+}
+
+// failingNotifications is a notification store whose attendee notifications fail, to
+// interrupt a write after the event itself has been saved.
+type failingNotifications struct {
+	database.NotificationStore
+}
+
+func (failingNotifications) InsertForAttendees(context.Context, int, string, string) (int64, error) {
+	return 0, errors.New("notifications unavailable")
+}
+
+func TestUpdateEventRollsBackOnFailure(t *testing.T) {
+	ts := newTestServer(t)
+	_, ownerToken := ts.signUp(t, "owner")
+	guest, _ := ts.signUp(t, "guest")
+
+	event := ts.createEvent(t, ownerToken)
+	ts.addAttendee(t, ownerToken, event.Id, guest.Id)
+
+	body := newEventBody()
+	body["location"] = "Somewhere else"
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+
+	notifications := ts.app.models.Notifications
+	ts.app.models.Notifications = failingNotifications{notifications}
+
+	rec := ts.request(t, http.MethodPut, path, ownerToken, body, "If-Match", `"1"`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("update event: status %d, want 500", rec.Code)
+	}
+
+	rec = ts.request(t, http.MethodGet, path, ownerToken, nil)
+	var stored database.Event
+	decode(t, rec, &stored)
+	if stored.Version != 1 || stored.Location != "Somewhere nice" {
+		t.Fatalf("event = %+v, want it unchanged", stored)
+	}
+
+	ts.app.models.Notifications = notifications
+
+	rec = ts.request(t, http.MethodPut, path, ownerToken, body, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry update event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = ts.request(t, http.MethodGet, path+"/revisions", ownerToken, nil)
+	var revisions []*database.EventRevision
+	decode(t, rec, &revisions)
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+}
+
+// failingEventLookups is an event store whose lookups by ID fail.
+type failingEventLookups struct {
+	database.EventStore
+}
+
+func (failingEventLookups) GetById(context.Context, int) (*database.Event, error) {
+	return nil, errors.New("events unavailable")
+}
+
+func TestGetEventByIdReportsStoreErrors(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+	event := ts.createEvent(t, token)
+
+	ts.app.models.Events = failingEventLookups{ts.app.models.Events}
+
+	rec := ts.request(t, http.MethodGet, fmt.Sprintf("/api/v1/events/%d", event.Id), token, nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("get event: status %d, want 500", rec.Code)
+	}
+}
+
+func TestNonOwnersAreForbidden(t *testing.T) {
+	ts := newTestServer(t)
+	_, ownerToken := ts.signUp(t, "owner")
+	other, otherToken := ts.signUp(t, "other")
+
+	event := ts.createEvent(t, ownerToken)
+	ts.addAttendee(t, ownerToken, event.Id, other.Id)
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+
+	for _, tc := range []struct {
+		method, path string
+		headers      []string
+	}{
+		{http.MethodDelete, path, []string{"If-Match", `"1"`}},
+		{http.MethodPost, fmt.Sprintf("%s/attendees/%d", path, other.Id), nil},
+		{http.MethodDelete, fmt.Sprintf("%s/attendees/%d", path, other.Id), nil},
+	} {
+		rec := ts.request(t, tc.method, tc.path, otherToken, nil, tc.headers...)
+		var body problem
+		decode(t, rec, &body)
+		if rec.Code != http.StatusForbidden || body.Code != codeNotEventOwner {
+			t.Errorf("%s %s: status %d, code %q; want 403 %s", tc.method, tc.path, rec.Code, body.Code, codeNotEventOwner)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-event-crud/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the checks behind /readyz, so a stuck database makes the
+// instance unready instead of hanging the probe.
+const readinessTimeout = 2 * time.Second
+
+// healthResponse reports the outcome of a health check and, for readiness, of each of the
+// checks it is made of.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthz is the liveness check: it answers as long as the process can serve requests, so
+// an orchestrator only restarts the instance when it is wedged.
+func (app *application) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// readyz is the readiness check: the instance is ready when it is not shutting down, the
+// database answers, and the schema is at the version this build expects.
+func (app *application) readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":   "ok",
+		"migrations": "ok",
+	}
+	ready := true
+
+	if app.shuttingDown.Load() {
+		checks["server"] = "shutting down"
+		ready = false
+	}
+
+	// The checks are public, so failures are reported as fixed strings and the errors,
+	// which can name hosts and users, are only logged.
+	if err := app.db.PingContext(ctx); err != nil {
+		requestLogger(c).Error("readiness check: database ping failed", "error", err)
+		checks["database"] = "unreachable"
+		ready = false
+	}
+
+	version, dirty, err := database.SchemaVersion(ctx, app.db)
+	switch {
+	case err != nil:
+		requestLogger(c).Error("readiness check: failed to read schema version", "error", err)
+		checks["migrations"] = "unknown"
+		ready = false
+	case dirty:
+		checks["migrations"] = fmt.Sprintf("version %d is dirty", version)
+		ready = false
+	case version != app.schemaVersion:
+		checks["migrations"] = fmt.Sprintf("at version %d, expected %d", version, app.schemaVersion)
+		ready = false
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Checks: checks})
+		return
+	}
+	c.JSON(http.StatusOK, healthResponse{Status: "ready", Checks: checks})
+}
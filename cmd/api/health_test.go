@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-event-crud/internal/database"
+)
+
+func TestReadyzHidesDatabaseErrors(t *testing.T) {
+	ts := newTestServer(t)
+
+	db, _, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.Close()
+	ts.app.db = db
+
+	rec := ts.request(t, http.MethodGet, "/readyz", "", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz: status %d, want 503", rec.Code)
+	}
+
+	var health healthResponse
+	decode(t, rec, &health)
+	if health.Checks["database"] != "unreachable" || health.Checks["migrations"] != "unknown" {
+		t.Fatalf("checks = %v; want fixed strings", health.Checks)
+	}
+	if strings.Contains(rec.Body.String(), "closed") {
+		t.Fatalf("readyz body %s leaks the database error", rec.Body)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-event-crud/internal/config"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/mail"
+	"go-event-crud/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testServer is the API running on in-memory models.
+type testServer struct {
+	app     *application
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	templates, err := mail.LoadTemplates()
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.RateLimit.Enabled = false
+	models := database.NewMemoryModels()
+
+	app := &application{
+		config:        cfg,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		publishWake:   make(chan struct{}, 1),
+		mailTemplates: templates,
+		metrics:       newMetrics(),
+		rateLimits:    ratelimit.NewMemoryStore(),
+		jobQueue:      jobs.New(models.Jobs, jobs.Config{Concurrency: 1, Lease: cfg.Jobs.Lease, MaxAttempts: 1}),
+		adminEmails:   emailSet(nil),
+		models:        models,
+	}
+
+	app.registerJobs()
+
+	return &testServer{app: app, handler: app.routes()}
+}
+
+// request sends a request to the API, signed in with token unless it is empty, and
+// returns the response. body is sent as JSON unless it is nil.
+func (ts *testServer) request(t *testing.T, method, path, token string, body any, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// signUp registers a user and returns it with a token for it.
+func (ts *testServer) signUp(t *testing.T, name string) (*database.User, string) {
+	t.Helper()
+
+	credentials := map[string]string{"name": name, "email": name + "@example.com", "password": "password123"}
+
+	rec := ts.request(t, http.MethodPost, "/api/v1/register", "", credentials)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register %s: status %d: %s", name, rec.Code, rec.Body)
+	}
+
+	var user database.User
+	decode(t, rec, &user)
+
+	rec = ts.request(t, http.MethodPost, "/api/v1/login", "", credentials)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login %s: status %d: %s", name, rec.Code, rec.Body)
+	}
+
+	var login loginResponse
+	decode(t, rec, &login)
+	return &user, login.Token
+}
+
+// createEvent creates a published event owned by the holder of token.
+func (ts *testServer) createEvent(t *testing.T, token string) *database.Event {
+	t.Helper()
+
+	rec := ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var event database.Event
+	decode(t, rec, &event)
+	return &event
+}
+
+// addAttendee adds a user to an event as its owner.
+func (ts *testServer) addAttendee(t *testing.T, token string, eventId, userId int) {
+	t.Helper()
+
+	rec := ts.request(t, http.MethodPost, fmt.Sprintf("/api/v1/events/%d/attendees/%d", eventId, userId), token, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add attendee: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// notifications returns the notifications of the holder of token, newest first.
+func (ts *testServer) notifications(t *testing.T, token string) []*database.Notification {
+	t.Helper()
+
+	rec := ts.request(t, http.MethodGet, "/api/v1/notifications", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get notifications: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var inbox struct {
+		Notifications []*database.Notification `json:"notifications"`
+	}
+	decode(t, rec, &inbox)
+	return inbox.Notifications
+}
+
+func newEventBody() map[string]any {
+	return map[string]any{
+		"ownerId":     1,
+		"name":        "Party time",
+		"description": "A fun party for all",
+		"date":        "2030-01-01",
+		"location":    "Somewhere nice",
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+func countType(notifications []*database.Notification, notificationType string) int {
+	n := 0
+	for _, notification := range notifications {
+		if notification.Type == notificationType {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/logging"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// capturingWriter keeps a copy of the response body so it can be stored for replay.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestFingerprint identifies the request a key was first used with, so the same key
+// cannot be reused for a different request.
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// IdempotencyMiddleware makes POST requests that carry an Idempotency-Key header safe to
+// retry. The first response for a key is stored and replayed for later requests with the
+// same key and body; reusing a key with a different request is rejected. Keys are scoped
+// to the authenticated user, so the middleware must run after AuthMiddleware.
+func (app *application) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			app.errorResponse(c, http.StatusBadRequest, codeIdempotencyKeyInvalid, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			app.errorResponse(c, http.StatusBadRequest, codeMalformedRequest, "The request body could not be read")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := app.GetUserFromContext(c)
+		fingerprint := requestFingerprint(c, body)
+
+		existing, err := app.models.IdempotencyKeys.Get(c.Request.Context(), user.Id, key)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				app.errorResponse(c, http.StatusUnprocessableEntity, codeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+			case existing.StatusCode == nil:
+				app.errorResponse(c, http.StatusConflict, codeIdempotencyKeyInUse, "A request with this Idempotency-Key is still being processed")
+			default:
+				for name, values := range existing.ResponseHeaders {
+					c.Writer.Header()[name] = values
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(*existing.StatusCode, existing.ContentType, existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		record := &database.IdempotencyKey{
+			UserId:      user.Id,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(app.config.Events.IdempotencyKeyTTL),
+		}
+
+		claimed, err := app.models.IdempotencyKeys.Insert(c.Request.Context(), record)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+
+		if !claimed {
+			app.errorResponse(c, http.StatusConflict, codeIdempotencyKeyInUse, "A request with this Idempotency-Key is still being processed")
+			return
+		}
+
+		// The key is released unless the response is stored, so the client can retry with
+		// the same key. This covers a handler that panics, which would otherwise leave the key
+		// claimed until it expires.
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := app.models.IdempotencyKeys.Delete(context.WithoutCancel(c.Request.Context()), user.Id, key); err != nil {
+				requestLogger(c).Error("failed to release idempotency key", "error", err)
+			}
+		}()
+
+		// Headers set before the handler runs, such as the request ID, belong to this
+		// request only and are not replayed.
+		before := c.Writer.Header().Clone()
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		// Server errors and rate limited requests are not stored, so the client can retry
+		// them with the same key.
+		if writer.Status() >= http.StatusInternalServerError || writer.Status() == http.StatusTooManyRequests {
+			return
+		}
+		stored = true
+
+		status := writer.Status()
+		record.StatusCode = &status
+		record.ContentType = writer.Header().Get("Content-Type")
+		record.ResponseHeaders = responseHeaders(before, writer.Header())
+		record.ResponseBody = writer.body.Bytes()
+
+		// The handler has already run, so the response must be stored even if the client has
+		// gone away in the meantime.
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		if err := app.models.IdempotencyKeys.Complete(ctx, record); err != nil {
+			requestLogger(c).Error("failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// responseHeaders returns the headers of a response that the handler set or changed,
+// leaving out the Content-Type, which is stored on its own, and the Content-Length, which
+// the replay sets again.
+func responseHeaders(before, after http.Header) http.Header {
+	headers := http.Header{}
+	for name, values := range after {
+		if name == "Content-Type" || name == "Content-Length" || slices.Equal(before[name], values) {
+			continue
+		}
+		headers[name] = slices.Clone(values)
+	}
+	return headers
+}
+
+// purgeIdempotencyKeys runs until ctx is done, removing keys whose TTL has passed.
+func (app *application) purgeIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := app.models.IdempotencyKeys.DeleteExpired(ctx, time.Now())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to purge idempotency keys", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go-event-crud/internal/database"
+)
+
+func TestIdempotencyKeyReplaysResponse(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+
+	first := ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody(), "Idempotency-Key", "create-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("create event: status %d: %s", first.Code, first.Body)
+	}
+
+	retry := ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody(), "Idempotency-Key", "create-1")
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: status %d, Idempotent-Replayed %q; want a replayed 201", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Fatalf("replayed body = %s, want %s", retry.Body, first.Body)
+	}
+	if etag := retry.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("replayed ETag = %q, want \"1\"", etag)
+	}
+	if id := retry.Header().Get("X-Request-ID"); id == "" || id == first.Header().Get("X-Request-ID") {
+		t.Fatalf("replayed X-Request-ID = %q; want the retry's own request ID", id)
+	}
+}
+
+// panickingEvents is an event store whose inserts panic.
+type panickingEvents struct {
+	database.EventStore
+}
+
+func (panickingEvents) Insert(context.Context, *database.Event) error {
+	panic("insert failed")
+}
+
+func TestIdempotencyKeyReleasedAfterPanic(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+
+	events := ts.app.models.Events
+	ts.app.models.Events = panickingEvents{events}
+
+	rec := ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody(), "Idempotency-Key", "create-1")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("create event: status %d, want 500", rec.Code)
+	}
+
+	ts.app.models.Events = events
+
+	rec = ts.request(t, http.MethodPost, "/api/v1/events", token, newEventBody(), "Idempotency-Key", "create-1")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("retry after a panic: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/logging"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultJobLimit = 50
+
+// registerJobs registers the handler of every job type the API queues. It must be called
+// before the workers start.
+func (app *application) registerJobs() {
+	jobs.Handle(app.jobQueue, purgeTrashJob, app.purgeTrash)
+	jobs.Handle(app.jobQueue, sendEmailJob, app.sendEmail)
+	jobs.Handle(app.jobQueue, sendRemindersJob, app.sendReminders)
+}
+
+// enqueueOnce calls enqueue unless a job of the named type is already pending or running,
+// so a recurring job queued by several instances only runs once per period. It returns
+// the job it queued, or nil if there was one already.
+func (app *application) enqueueOnce(ctx context.Context, jobType string, enqueue func(ctx context.Context) (*database.Job, error)) (*database.Job, error) {
+	var job *database.Job
+	err := app.models.Transact(ctx, func(ctx context.Context) error {
+		for _, status := range []string{database.JobStatusPending, database.JobStatusRunning} {
+			queued, err := app.models.Jobs.List(ctx, database.JobFilter{Status: status, Type: jobType, Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(queued) > 0 {
+				return nil
+			}
+		}
+
+		var err error
+		job, err = enqueue(ctx)
+		return err
+	})
+	return job, err
+}
+
+type jobsQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending running succeeded failed"`
+	Type   string `form:"type"`
+	Before int    `form:"before" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type jobsResponse struct {
+	Jobs []*database.Job `json:"jobs"`
+}
+
+// getJobs godoc
+//
+//	@Summary		List background jobs
+//	@Description	List background jobs, newest first (requires an administrator).
+//	@Description	Pass the ID of the last job as "before" to get the next page.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			status	query		string	false	"Only list jobs with this status"	Enums(pending, running, succeeded, failed)
+//	@Param			type	query		string	false	"Only list jobs of this type"
+//	@Param			before	query		int		false	"Only list jobs older than this ID"
+//	@Param			limit	query		int		false	"Number of jobs to list, up to 100"	default(50)
+//	@Success		200		{object}	jobsResponse
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		403		{object}	problem
+//	@Failure		500		{object}	problem
+//	@Security		BearerAuth
+//	@Router			/admin/jobs [get]
+func (app *application) getJobs(c *gin.Context) {
+	var query jobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultJobLimit
+	}
+
+	jobs, err := app.models.Jobs.List(c.Request.Context(), database.JobFilter{
+		Status: query.Status,
+		Type:   query.Type,
+		Before: query.Before,
+		Limit:  query.Limit,
+	})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobsResponse{Jobs: jobs})
+}
+
+// getJob godoc
+//
+//	@Summary		Get a background job
+//	@Description	Get a background job with its payload and last error (requires an administrator)
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path		int	true	"Job ID"
+//	@Success		200	{object}	database.Job
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		403	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/admin/jobs/{id} [get]
+func (app *application) getJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidJobId, "Invalid job ID")
+		return
+	}
+
+	job, err := app.models.Jobs.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if job == nil {
+		app.errorResponse(c, http.StatusNotFound, codeJobNotFound, "Job not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// retryJob godoc
+//
+//	@Summary		Retry a failed background job
+//	@Description	Queue a failed job to run again now, with all of its attempts (requires an administrator)
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path		int	true	"Job ID"
+//	@Success		200	{object}	database.Job
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		403	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		409	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/admin/jobs/{id}/retry [post]
+func (app *application) retryJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidJobId, "Invalid job ID")
+		return
+	}
+
+	job, err := app.models.Jobs.Retry(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if job == nil {
+		existing, err := app.models.Jobs.GetById(c.Request.Context(), id)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+
+		if existing == nil {
+			app.errorResponse(c, http.StatusNotFound, codeJobNotFound, "Job not found")
+			return
+		}
+
+		app.errorResponse(c, http.StatusConflict, codeJobNotFailed, "Only failed jobs can be retried")
+		return
+	}
+
+	app.jobQueue.Wake()
+	c.JSON(http.StatusOK, job)
+}
+
+// purgeJobs runs until ctx is done, deleting jobs that succeeded more than jobs.retention
+// ago every interval.
+func (app *application) purgeJobs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := app.models.Jobs.Purge(ctx, time.Now().Add(-app.config.Jobs.Retention))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to purge jobs", "error", err)
+		} else if purged > 0 {
+			logging.FromContext(ctx).Info("purged jobs", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// component is a part of the application with a start and a stop step, such as the
+// database, the background workers or the HTTP server.
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// lifecycle starts components in the order they were added and stops them in reverse, so
+// every component can rely on the ones added before it while it runs and while it stops.
+type lifecycle struct {
+	logger     *slog.Logger
+	components []component
+	started    int
+}
+
+func (l *lifecycle) add(c component) {
+	l.components = append(l.components, c)
+}
+
+// start starts every component in order. If one fails, the components started before it
+// are stopped again.
+func (l *lifecycle) start(ctx context.Context) error {
+	for _, c := range l.components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				err = fmt.Errorf("starting %s: %w", c.name, err)
+				return errors.Join(err, l.stop(ctx))
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// stop stops the started components in reverse order. Every component gets to stop even
+// if one before it failed to.
+func (l *lifecycle) stop(ctx context.Context) error {
+	var errs []error
+	for ; l.started > 0; l.started-- {
+		c := l.components[l.started-1]
+		if c.stop == nil {
+			continue
+		}
+
+		l.logger.Info("stopping", "component", c.name)
+		if err := c.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go-event-crud/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIdHeader    = "X-Request-ID"
+	maxRequestIdLength = 128
+)
+
+// configureGin sends Gin's debug output, such as the routes it registers, through logger
+// instead of printing it among the logs on stdout. Below the debug level there is nothing
+// to log, so Gin runs in release mode.
+func configureGin(logger *slog.Logger, level slog.Level) {
+	if level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+		return
+	}
+
+	logger = logger.With("component", "gin")
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		logger.Debug("route registered", "method", method, "path", path, "handler", handler, "handlers", handlers)
+	}
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		logger.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+}
+
+// RequestIDMiddleware gives every request an ID, taken from its X-Request-ID header when
+// the client or a proxy sent a usable one and generated otherwise, and echoes it in the
+// response. Handlers log through a logger in the request context that carries the ID, and
+// the trace ID when the request is traced.
+func (app *application) RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIdHeader)
+		if !validRequestId(id) {
+			id = newCorrelationId()
+		}
+		c.Header(requestIdHeader, id)
+
+		logger := app.logger.With("request_id", id)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+		setRequestLogger(c, logger)
+
+		c.Next()
+	}
+}
+
+// validRequestId accepts IDs of printable ASCII without spaces and of reasonable length,
+// so a client cannot inject log lines or bloat every entry.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware logs every request once it has been served, with the request
+// logger, so the entry carries the request ID and, after authentication, the user ID.
+func (app *application) AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+
+		requestLogger(c).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
+
+// requestLogger returns the logger of the request.
+func requestLogger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
+// setRequestLogger makes logger the logger of the request, for handlers and for the query
+// hooks, which log through the context they are given.
+func setRequestLogger(c *gin.Context, logger *slog.Logger) {
+	c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), logger))
+}
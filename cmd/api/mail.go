@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/logging"
+	"go-event-crud/internal/mail"
+	"maps"
+	"net/url"
+	"time"
+)
+
+// emailSendTimeout bounds a single SMTP exchange.
+const emailSendTimeout = 10 * time.Second
+
+// sendEmailJob sends an email from the outbox. It is tried up to mail.max_attempts times.
+var sendEmailJob = jobs.NewType[sendEmailPayload]("send_email")
+
+type sendEmailPayload struct {
+	EmailId int `json:"emailId"`
+}
+
+// queueEmail renders a template for a user in their locale and queues it, unless emails
+// are off or the user has turned off category. Emails with an empty category, such as
+// password resets, cannot be turned off and carry no unsubscribe link.
+//
+// Called inside a transaction, the email is only queued if the transaction commits.
+func (app *application) queueEmail(ctx context.Context, user *database.User, category, template string, data map[string]any) error {
+	if !app.config.Mail.Enabled() {
+		return nil
+	}
+
+	prefs, err := app.models.EmailPreferences.Get(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if !prefs.Allows(category) {
+		return nil
+	}
+
+	unsubscribeURL := ""
+	if category != "" {
+		unsubscribeURL = app.unsubscribeURL(prefs.UnsubscribeToken, category)
+	}
+
+	data["Name"] = user.Name
+	data["UnsubscribeURL"] = unsubscribeURL
+
+	message, err := app.mailTemplates.Render(template, prefs.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	email := &database.Email{
+		UserId:         user.Id,
+		Recipient:      user.Email,
+		Template:       template,
+		Subject:        message.Subject,
+		TextBody:       message.Text,
+		HTMLBody:       message.HTML,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	return app.models.Transact(ctx, func(ctx context.Context) error {
+		if err := app.models.Emails.Enqueue(ctx, email); err != nil {
+			return err
+		}
+
+		_, err := sendEmailJob.Enqueue(ctx, app.jobQueue, sendEmailPayload{EmailId: email.Id}, jobs.MaxAttempts(app.config.Mail.MaxAttempts))
+		return err
+	})
+}
+
+// queueEventEmail queues an email about an event to the user with the given ID.
+func (app *application) queueEventEmail(ctx context.Context, userId int, event *database.Event, category, template string, data map[string]any) error {
+	user, err := app.models.Users.GetById(ctx, userId)
+	if err != nil || user == nil {
+		return err
+	}
+
+	return app.queueEmail(ctx, user, category, template, eventEmailData(event, data))
+}
+
+// emailAttendees queues an email about an event to each of its attendees other than its
+// owner.
+func (app *application) emailAttendees(ctx context.Context, event *database.Event, category, template string, data map[string]any) error {
+	if !app.config.Mail.Enabled() {
+		return nil
+	}
+
+	attendees, err := app.models.Attendees.GetAttendeesByEvent(ctx, event.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, attendee := range attendees {
+		if attendee.Id == event.OwnerId {
+			continue
+		}
+
+		if err := app.queueEmail(ctx, &attendee, category, template, eventEmailData(event, data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventEmailData returns a copy of data with the details of an event added.
+func eventEmailData(event *database.Event, data map[string]any) map[string]any {
+	data = maps.Clone(data)
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["EventName"] = event.Name
+	data["EventDate"] = event.Date[:10]
+	data["EventLocation"] = event.Location
+	return data
+}
+
+// unsubscribeURL returns the link that turns off category for the owner of token.
+func (app *application) unsubscribeURL(token, category string) string {
+	query := url.Values{"token": {token}, "category": {category}}
+	return app.config.Mail.BaseURL + "/api/v1/unsubscribe?" + query.Encode()
+}
+
+// sendEmail is the handler of sendEmailJob. An email that is already sent, or whose user
+// has been deleted since, is skipped.
+func (app *application) sendEmail(ctx context.Context, payload sendEmailPayload) error {
+	email, err := app.models.Emails.GetById(ctx, payload.EmailId)
+	if err != nil {
+		return err
+	}
+	if email == nil || email.Status == database.EmailStatusSent {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx).With("email_id", email.Id, "template", email.Template)
+
+	sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+	err = app.mailer.Send(sendCtx, mail.Message{
+		To:             email.Recipient,
+		Subject:        email.Subject,
+		Text:           email.TextBody,
+		HTML:           email.HTMLBody,
+		UnsubscribeURL: email.UnsubscribeURL,
+	})
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			app.metrics.emailDeliveries.WithLabelValues(email.Template, "failed").Inc()
+		}
+		return err
+	}
+
+	app.metrics.emailDeliveries.WithLabelValues(email.Template, "sent").Inc()
+	logger.Info("email sent")
+
+	// The email has gone out, so a failure to record it is not worth sending it again for.
+	if err := app.models.Emails.MarkSent(ctx, email.Id); err != nil {
+		logger.Error("failed to mark email sent", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/mail"
+)
+
+// recordingMailer is a mail sender that keeps what it sends, or fails with err if set.
+type recordingMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *recordingMailer) Send(_ context.Context, message mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func TestInvitationIsSentByJob(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.config.Mail.SMTPAddr = "smtp.test:25"
+	mailer := &recordingMailer{err: errors.New("421 try again later")}
+	ts.app.mailer = mailer
+
+	_, ownerToken := ts.signUp(t, "owner")
+	guest, _ := ts.signUp(t, "guest")
+	event := ts.createEvent(t, ownerToken)
+	ts.addAttendee(t, ownerToken, event.Id, guest.Id)
+
+	ctx := context.Background()
+	queued, err := ts.app.models.Jobs.List(ctx, database.JobFilter{Type: sendEmailJob.Name(), Limit: 10})
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("send_email jobs = %+v, %v; want one for the invitation", queued, err)
+	}
+
+	var payload sendEmailPayload
+	if err := json.Unmarshal(queued[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.app.sendEmail(ctx, payload); err == nil {
+		t.Fatal("sendEmail with a failing mailer succeeded, want an error so the job is retried")
+	}
+
+	mailer.err = nil
+	for range 2 {
+		if err := ts.app.sendEmail(ctx, payload); err != nil {
+			t.Fatalf("sendEmail: %v", err)
+		}
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].To != guest.Email {
+		t.Fatalf("sent = %+v, want the invitation sent to %s once", mailer.sent, guest.Email)
+	}
+
+	email, err := ts.app.models.Emails.GetById(ctx, payload.EmailId)
+	if err != nil || email == nil || email.Status != database.EmailStatusSent {
+		t.Fatalf("email = %+v, %v; want it marked sent", email, err)
+	}
+}
@@ -14,42 +14,179 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
+	"go-event-crud/internal/config"
 	"go-event-crud/internal/database"
-	"go-event-crud/internal/env"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/logging"
+	"go-event-crud/internal/mail"
+	"go-event-crud/internal/ratelimit"
+	"go-event-crud/internal/tracing"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	_ "go-event-crud/docs" // Import generated docs
 
 	_ "github.com/joho/godotenv/autoload"
-	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
 )
 
 type application struct {
-	port      int
-	jwtSecret string
-	models    database.Models
+	config        config.Config
+	logger        *slog.Logger
+	publishWake   chan struct{}
+	mailTemplates *mail.Templates
+	mailer        mail.Sender
+	jobQueue      *jobs.Queue
+	adminEmails   map[string]bool
+	backupMu      sync.Mutex
+	shuttingDown  atomic.Bool
+	schemaVersion uint
+	metrics       *metrics
+	rateLimits    ratelimit.Store
+	stopTracing   func(context.Context) error
+	workers       sync.WaitGroup
+	cancelWorkers context.CancelFunc
+	db            *sql.DB
+	models        database.Models
 }
 
 func main() {
-	db, err := sql.Open("sqlite3", "./data.db")
-	if err != nil {
+	cfg := config.Default()
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	if err := cfg.Load(flags, os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 
-	defer db.Close()
+	if *printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.SlogLevel())
+	// the log package, and so libraries that use it, write through the logger too
+	slog.SetDefault(logger)
+	configureGin(logger, cfg.Log.SlogLevel())
+
+	if cfg.Auth.JWTSecret == config.DefaultJWTSecret {
+		logger.Warn("auth.jwt_secret is the built-in development secret; set JWT_SECRET before deploying")
+	}
+
+	if cfg.Database.AutoMigrate {
+		version, err := database.MigrateUp(cfg.Database.URL)
+		if err != nil {
+			fatal(logger, "failed to migrate database", err)
+		}
+		logger.Info("database migrated", "version", version)
+	}
+
+	db, dialect, err := database.Open(cfg.Database.URL)
+	if err != nil {
+		fatal(logger, "failed to open database", err)
+	}
+
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+
+	// the version readiness checks expect the schema to be at
+	schemaVersion, err := database.LatestMigration(dialect)
+	if err != nil {
+		fatal(logger, "failed to read migrations", err)
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal(logger, "failed to set up tracing", err)
+	}
+
+	metrics := newMetrics()
+	if err := database.RegisterPoolMetrics(metrics.registry, db, dialect); err != nil {
+		fatal(logger, "failed to register metrics", err)
+	}
+	queryMetrics, err := database.NewQueryMetrics(metrics.registry)
+	if err != nil {
+		fatal(logger, "failed to register metrics", err)
+	}
+
+	mailTemplates, err := mail.LoadTemplates()
+	if err != nil {
+		fatal(logger, "failed to load email templates", err)
+	}
+	if !cfg.Mail.Enabled() {
+		logger.Info("mail.smtp_addr is not set; emails are off")
+	}
 
 	// init modals
-	models := database.NewModels(db)
+	models := database.NewModels(db, dialect, database.QueryOptions{
+		Timeout:      cfg.Database.QueryTimeout,
+		BatchTimeout: cfg.Database.BatchTimeout,
+		Hooks: []database.QueryHook{
+			database.NewQueryTracer(otel.GetTracerProvider(), dialect),
+			slowQueryLogger{threshold: cfg.Database.SlowQuery},
+			queryMetrics,
+		},
+	})
+
+	jobQueue := jobs.New(models.Jobs, jobs.Config{
+		Concurrency: cfg.Jobs.Concurrency,
+		Lease:       cfg.Jobs.Lease,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+	})
+	jobQueue.OnFinish = func(job *database.Job, result string) {
+		metrics.jobs.WithLabelValues(job.Type, result).Inc()
+	}
 
 	app := &application{
-		port:      env.GetEnvInt("PORT", 6969),
-		jwtSecret: env.GetEnvString("JWT_SECRET", "random-secret"),
-		models:    models,
+		config:        cfg,
+		logger:        logger,
+		publishWake:   make(chan struct{}, 1),
+		mailTemplates: mailTemplates,
+		mailer: &mail.SMTPSender{
+			Addr:     cfg.Mail.SMTPAddr,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.FromAddress(),
+		},
+		adminEmails:   emailSet(cfg.Auth.AdminEmails),
+		schemaVersion: schemaVersion,
+		metrics:       metrics,
+		rateLimits:    ratelimit.NewMemoryStore(),
+		jobQueue:      jobQueue,
+		stopTracing:   stopTracing,
+		db:            db,
+		models:        models,
 	}
 
+	app.registerJobs()
+
+	// serve owns the database from here on and closes it when it stops
 	if err := app.serve(); err != nil {
-		log.Fatal(err)
+		fatal(logger, "server failed", err)
 	}
 
 }
+
+// fatal logs an error that stops the application and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// emailSet turns a list of email addresses into a set keyed by lowercase address.
+func emailSet(list []string) map[string]bool {
+	emails := make(map[string]bool, len(list))
+	for _, email := range list {
+		emails[strings.ToLower(email)] = true
+	}
+	return emails
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+    "context"
     "net/http"
     "strings"
 
@@ -12,49 +13,86 @@ func (app *application) AuthMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-            c.Abort()
+            app.errorResponse(c, http.StatusUnauthorized, codeMissingAuthorization, "Authorization header is required")
             return
         }
 
         tokenString := strings.TrimPrefix(authHeader, "Bearer ")
         if tokenString == authHeader {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token is required"})
-            c.Abort()
+            app.errorResponse(c, http.StatusUnauthorized, codeMissingAuthorization, "Bearer token is required")
             return
         }
 
-        token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, jwt.ErrSignatureInvalid
-            }
-            return []byte(app.jwtSecret), nil
-        })
-
-        if err != nil || !token.Valid {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-            c.Abort()
+        userId, ok := app.parseToken(c.Request.Context(), tokenString)
+        if !ok {
+            app.errorResponse(c, http.StatusUnauthorized, codeInvalidToken, "Invalid token")
             return
         }
 
-        claims, ok := token.Claims.(jwt.MapClaims)
-        if !ok {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-            c.Abort()
+        user, err := app.models.Users.GetById(c.Request.Context(), userId)
+        if err != nil {
+            app.serverErrorResponse(c, err)
             return
         }
 
-        userId := claims["userId"].(float64)
-
-        user, err := app.models.Users.GetById(int(userId))
-        if err != nil {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
-            c.Abort()
+        if user == nil {
+            app.errorResponse(c, http.StatusUnauthorized, codeInvalidToken, "The user this token was issued for no longer exists")
             return
         }
 
         c.Set("user", user)
+        setRequestLogger(c, requestLogger(c).With("user_id", user.Id))
 
         c.Next()
     }
+}
+
+// OptionalAuthMiddleware sets the user like AuthMiddleware when a valid bearer token is
+// sent, but lets anonymous requests through so public routes can tailor their response.
+func (app *application) OptionalAuthMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+        if userId, ok := app.parseToken(c.Request.Context(), tokenString); ok {
+            if user, err := app.models.Users.GetById(c.Request.Context(), userId); err == nil && user != nil {
+                c.Set("user", user)
+                setRequestLogger(c, requestLogger(c).With("user_id", user.Id))
+            }
+        }
+
+        c.Next()
+    }
+}
+
+// parseToken validates a signed JWT and returns the user ID it was issued for.
+func (app *application) parseToken(ctx context.Context, tokenString string) (int, bool) {
+    if tokenString == "" {
+        return 0, false
+    }
+
+    _, span := tracer.Start(ctx, "jwt.Parse")
+    defer span.End()
+
+    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+            return nil, jwt.ErrSignatureInvalid
+        }
+        return []byte(app.config.Auth.JWTSecret), nil
+    })
+
+    if err != nil || !token.Valid {
+        return 0, false
+    }
+
+    claims, ok := token.Claims.(jwt.MapClaims)
+    if !ok {
+        return 0, false
+    }
+
+    userId, ok := claims["userId"].(float64)
+    if !ok {
+        return 0, false
+    }
+
+    return int(userId), true
 }
\ No newline at end of file
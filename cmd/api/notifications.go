@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/mail"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultNotificationLimit = 50
+
+type notificationsQuery struct {
+	Unread bool `form:"unread"`
+	Before int  `form:"before" binding:"omitempty,min=1"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type notificationsResponse struct {
+	Notifications []*database.Notification `json:"notifications"`
+	UnreadCount   int                      `json:"unreadCount"`
+}
+
+type markAllReadResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+// getNotifications godoc
+//
+//	@Summary		List notifications
+//	@Description	List the authenticated user's notifications, newest first, with the number of unread ones.
+//	@Description	Pass the ID of the last notification as "before" to get the next page.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only list unread notifications"
+//	@Param			before	query		int		false	"Only list notifications older than this ID"
+//	@Param			limit	query		int		false	"Number of notifications to list, up to 100"	default(50)
+//	@Success		200		{object}	notificationsResponse
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		500		{object}	problem
+//	@Security		BearerAuth
+//	@Router			/notifications [get]
+func (app *application) getNotifications(c *gin.Context) {
+	var query notificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultNotificationLimit
+	}
+
+	user := app.GetUserFromContext(c)
+	notifications, err := app.models.Notifications.GetByUser(c.Request.Context(), user.Id, database.NotificationFilter{
+		UnreadOnly: query.Unread,
+		Before:     query.Before,
+		Limit:      query.Limit,
+	})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	unread, err := app.models.Notifications.CountUnread(c.Request.Context(), user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationsResponse{
+		Notifications: notifications,
+		UnreadCount:   unread,
+	})
+}
+
+// markNotificationRead godoc
+//
+//	@Summary		Mark a notification read
+//	@Description	Mark one of the authenticated user's notifications read. Marking it again keeps the time it was first read.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"Notification ID"
+//	@Success		204	"No Content"
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/notifications/{id}/read [post]
+func (app *application) markNotificationRead(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidNotificationId, "Invalid notification ID")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	found, err := app.models.Notifications.MarkRead(c.Request.Context(), user.Id, id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if !found {
+		app.errorResponse(c, http.StatusNotFound, codeNotificationNotFound, "Notification not found")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// markAllNotificationsRead godoc
+//
+//	@Summary		Mark all notifications read
+//	@Description	Mark every unread notification of the authenticated user read
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	markAllReadResponse
+//	@Failure		401	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/notifications/read [post]
+func (app *application) markAllNotificationsRead(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	marked, err := app.models.Notifications.MarkAllRead(c.Request.Context(), user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, markAllReadResponse{Marked: marked})
+}
+
+// notifyAttendeeChange notifies a user that they were added to or removed from an event,
+// unless they made the change themselves. A user who was added is also emailed an
+// invitation.
+func (app *application) notifyAttendeeChange(ctx context.Context, event *database.Event, userId, actorId int, notificationType string) error {
+	if userId == actorId {
+		return nil
+	}
+
+	message := fmt.Sprintf("You have been added to %q", event.Name)
+	if notificationType == database.NotificationAttendeeRemoved {
+		message = fmt.Sprintf("You have been removed from %q", event.Name)
+	}
+
+	err := app.models.Notifications.Insert(ctx, &database.Notification{
+		UserId:  userId,
+		EventId: event.Id,
+		Type:    notificationType,
+		Message: message,
+	})
+	if err != nil || notificationType != database.NotificationAttendeeAdded {
+		return err
+	}
+
+	return app.queueEventEmail(ctx, userId, event, database.EmailCategoryInvitations, mail.TemplateInvitation, nil)
+}
+
+// notifyEventUpdated queues a notification for every attendee when an update changes an
+// event. A new status or date is reported as notifyStatusChange does; other changes are
+// reported as a plain update.
+func (app *application) notifyEventUpdated(ctx context.Context, previous, current *database.Event) error {
+	if current.Status != previous.Status || !sameDay(current.Date, previous.Date) {
+		return app.notifyStatusChange(ctx, current, previous.Status, previous.Date)
+	}
+
+	if current.Name == previous.Name && current.Description == previous.Description &&
+		sameDay(current.Date, previous.Date) && current.Location == previous.Location {
+		return nil
+	}
+
+	message := fmt.Sprintf("%q has been updated", previous.Name)
+	_, err := app.models.Notifications.InsertForAttendees(ctx, current.Id, database.NotificationEventUpdated, message)
+	return err
+}
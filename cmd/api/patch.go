@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-event-crud/internal/database"
+	"io"
+	"net/http"
+	"strconv"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	mimeMergePatch = "application/merge-patch+json"
+	mimeJSONPatch  = "application/json-patch+json"
+)
+
+// eventPatchDocument is the part of an event that PATCH may change. Patches are applied
+// to this document rather than database.Event so they cannot touch ownership or status.
+type eventPatchDocument struct {
+	Name        string `json:"name" binding:"required,min=3"`
+	Description string `json:"description" binding:"required,min=10"`
+	Date        string `json:"date" binding:"required,datetime=2006-01-02"`
+	Location    string `json:"location" binding:"required,min=3"`
+}
+
+func newEventPatchDocument(event *database.Event) eventPatchDocument {
+	date := event.Date
+	if len(date) > 10 {
+		date = date[:10]
+	}
+
+	return eventPatchDocument{
+		Name:        event.Name,
+		Description: event.Description,
+		Date:        date,
+		Location:    event.Location,
+	}
+}
+
+// changes returns the columns that differ between two documents.
+func (d eventPatchDocument) changes(patched eventPatchDocument) map[string]any {
+	changes := map[string]any{}
+
+	if patched.Name != d.Name {
+		changes["name"] = patched.Name
+	}
+	if patched.Description != d.Description {
+		changes["description"] = patched.Description
+	}
+	if patched.Date != d.Date {
+		changes["date"] = patched.Date
+	}
+	if patched.Location != d.Location {
+		changes["location"] = patched.Location
+	}
+
+	return changes
+}
+
+// patchEvent godoc
+//
+//	@Summary		Partially update an event
+//	@Description	Apply an RFC 7396 JSON Merge Patch or an RFC 6902 JSON Patch to the name, description, date and location
+//	@Description	of an event (requires authentication and ownership). The patched event is validated like a full update
+//	@Description	and only the changed columns are written.
+//	@Tags			events
+//	@Accept			application/merge-patch+json
+//	@Accept			application/json-patch+json
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			If-Match	header		string	true	"ETag of the event being patched"
+//	@Param			patch		body		object	true	"Merge patch or JSON Patch document"
+//	@Success		200			{object}	database.Event
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		412			{object}	problem
+//	@Failure		415			{object}	problem
+//	@Failure		428			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id} [patch]
+func (app *application) patchEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != mimeMergePatch && contentType != mimeJSONPatch {
+		app.errorResponse(c, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be "+mimeMergePatch+" or "+mimeJSONPatch)
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	existingEvent, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if existingEvent == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if existingEvent.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to update this event")
+		return
+	}
+
+	if !app.checkIfMatch(c, existingEvent) {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeMalformedRequest, "The request body could not be read")
+		return
+	}
+
+	current := newEventPatchDocument(existingEvent)
+	original, err := json.Marshal(current)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	var patched []byte
+	if contentType == mimeMergePatch {
+		patched, err = jsonpatch.MergePatch(original, body)
+	} else {
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	}
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidPatch, "Invalid patch: "+err.Error())
+		return
+	}
+
+	var result eventPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidPatch, "Invalid patched event: "+err.Error())
+		return
+	}
+
+	if err := binding.Validator.ValidateStruct(&result); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	updatedEvent := *existingEvent
+	updatedEvent.Name = result.Name
+	updatedEvent.Description = result.Description
+	updatedEvent.Date = result.Date
+	updatedEvent.Location = result.Location
+
+	changes := current.changes(result)
+	if len(changes) == 0 {
+		c.Header("ETag", eventETag(existingEvent))
+		c.JSON(http.StatusOK, existingEvent)
+		return
+	}
+
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		updatedEvent.Version = existingEvent.Version
+
+		if err := app.models.Events.UpdateFields(ctx, &updatedEvent, changes); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				return errPreconditionFailed
+			}
+			return err
+		}
+
+		if err := app.recordEventRevision(ctx, existingEvent, &updatedEvent, user.Id); err != nil {
+			return err
+		}
+
+		return app.notifyEventUpdated(ctx, existingEvent, &updatedEvent)
+	})
+	if err != nil {
+		app.handleError(c, err)
+		return
+	}
+
+	c.Header("ETag", eventETag(&updatedEvent))
+	c.JSON(http.StatusOK, updatedEvent)
+}
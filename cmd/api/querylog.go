@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-event-crud/internal/logging"
+)
+
+type queryStartKey struct{}
+
+// slowQueryLogger is a database.QueryHook that logs model methods which fail or take
+// longer than threshold. Cancellations are not logged since the client went away.
+type slowQueryLogger struct {
+	threshold time.Duration
+}
+
+func (l slowQueryLogger) BeforeQuery(ctx context.Context, op string) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, time.Now())
+}
+
+func (l slowQueryLogger) AfterQuery(ctx context.Context, op string, err error) {
+	start, ok := ctx.Value(queryStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+	case errors.Is(err, context.DeadlineExceeded):
+		logging.FromContext(ctx).Warn("query timed out", "op", op, "duration_ms", elapsed.Milliseconds())
+	case err != nil:
+		logging.FromContext(ctx).Error("query failed", "op", op, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case elapsed >= l.threshold:
+		logging.FromContext(ctx).Warn("slow query", "op", op, "duration_ms", elapsed.Milliseconds())
+	}
+}
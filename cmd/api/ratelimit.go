@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-event-crud/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware limits requests by the named policy of the configuration, such as
+// "auth". Requests are counted per user when the user is signed in, so it must come after
+// the authentication middleware, and per client IP otherwise. Every response reports the
+// state of the bucket in RateLimit-* headers; a request with no token left gets a 429 and
+// a Retry-After header.
+//
+// A route can have several policies; the headers describe the one closest to its limit.
+func (app *application) RateLimitMiddleware(name string) gin.HandlerFunc {
+	if !app.config.RateLimit.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	policy := app.config.RateLimit.Policy(name)
+	return func(c *gin.Context) {
+		key := name + ":ip:" + c.ClientIP()
+		if user := app.GetUserFromContext(c); user.Id != 0 {
+			key = name + ":user:" + strconv.Itoa(user.Id)
+		}
+
+		result, err := app.rateLimits.Take(c.Request.Context(), key, policy)
+		if err != nil {
+			// a store that is down should not take the API down with it
+			requestLogger(c).Error("rate limit store failed", "policy", name, "error", err)
+			c.Next()
+			return
+		}
+
+		setRateLimitHeaders(c, policy, result)
+
+		if !result.Allowed {
+			app.metrics.rateLimited.WithLabelValues(name).Inc()
+			retryAfter := seconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			app.errorResponse(c, http.StatusTooManyRequests, codeRateLimited,
+				fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders reports the bucket in the headers of the IETF RateLimit header
+// fields draft, unless a policy applied before has fewer requests remaining.
+func setRateLimitHeaders(c *gin.Context, policy ratelimit.Policy, result ratelimit.Result) {
+	header := c.Writer.Header()
+	if prev, err := strconv.Atoi(header.Get("RateLimit-Remaining")); err == nil && prev <= result.Remaining {
+		return
+	}
+
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, seconds(policy.Period)))
+}
+
+// seconds rounds d up to whole seconds, so a client that waits that long is not too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-event-crud/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 0},
+		{time.Nanosecond, 1},
+		{999 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1001 * time.Millisecond, 2},
+		{2500 * time.Millisecond, 3},
+		{time.Minute, 60},
+	}
+
+	for _, tt := range tests {
+		if got := seconds(tt.d); got != tt.want {
+			t.Errorf("seconds(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSetRateLimitHeaders(t *testing.T) {
+	perMinute := ratelimit.Policy{Name: "api", Limit: 60, Period: time.Minute}
+	perHour := ratelimit.Policy{Name: "auth", Limit: 10, Period: time.Hour}
+
+	type take struct {
+		policy ratelimit.Policy
+		result ratelimit.Result
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+		want  map[string]string
+	}{
+		{
+			name:  "one policy",
+			takes: []take{{perMinute, ratelimit.Result{Allowed: true, Limit: 60, Remaining: 59, Reset: 1500 * time.Millisecond}}},
+			want:  map[string]string{"RateLimit-Limit": "60", "RateLimit-Remaining": "59", "RateLimit-Reset": "2", "RateLimit-Policy": "60;w=60"},
+		},
+		{
+			name: "a later policy with fewer requests remaining wins",
+			takes: []take{
+				{perMinute, ratelimit.Result{Allowed: true, Limit: 60, Remaining: 59, Reset: time.Second}},
+				{perHour, ratelimit.Result{Allowed: true, Limit: 10, Remaining: 3, Reset: 42 * time.Minute}},
+			},
+			want: map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "3", "RateLimit-Reset": "2520", "RateLimit-Policy": "10;w=3600"},
+		},
+		{
+			name: "a later policy with more requests remaining is ignored",
+			takes: []take{
+				{perHour, ratelimit.Result{Allowed: true, Limit: 10, Remaining: 3, Reset: 42 * time.Minute}},
+				{perMinute, ratelimit.Result{Allowed: true, Limit: 60, Remaining: 59, Reset: time.Second}},
+			},
+			want: map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "3", "RateLimit-Reset": "2520", "RateLimit-Policy": "10;w=3600"},
+		},
+		{
+			name: "a tie keeps the first policy",
+			takes: []take{
+				{perMinute, ratelimit.Result{Limit: 60, Remaining: 0, Reset: time.Minute, RetryAfter: time.Second}},
+				{perHour, ratelimit.Result{Limit: 10, Remaining: 0, Reset: time.Hour, RetryAfter: 6 * time.Minute}},
+			},
+			want: map[string]string{"RateLimit-Limit": "60", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "RateLimit-Policy": "60;w=60"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			for _, take := range tt.takes {
+				setRateLimitHeaders(c, take.policy, take.result)
+			}
+
+			for name, want := range tt.want {
+				if got := c.Writer.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/logging"
+	"go-event-crud/internal/mail"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type eventRemindersRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type eventRemindersResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// getEventReminders godoc
+//
+//	@Summary		Get event reminders
+//	@Description	Get whether the authenticated user is reminded of an event they attend
+//	@Tags			attendees
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	eventRemindersResponse
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/reminders [get]
+func (app *application) getEventReminders(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	enabled, err := app.models.Reminders.Enabled(c.Request.Context(), id, user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if enabled == nil {
+		app.errorResponse(c, http.StatusNotFound, codeAttendeeNotFound, "You are not attending this event")
+		return
+	}
+
+	c.JSON(http.StatusOK, eventRemindersResponse{Enabled: *enabled})
+}
+
+// updateEventReminders godoc
+//
+//	@Summary		Turn event reminders on or off
+//	@Description	Choose whether the authenticated user is reminded of an event they attend. Reminders are on when a user is added to an event.
+//	@Tags			attendees
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Event ID"
+//	@Param			reminders	body		eventRemindersRequest	true	"Whether to send reminders"
+//	@Success		200			{object}	eventRemindersResponse
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/reminders [put]
+func (app *application) updateEventReminders(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	var request eventRemindersRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	found, err := app.models.Reminders.SetEnabled(c.Request.Context(), id, user.Id, *request.Enabled)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if !found {
+		app.errorResponse(c, http.StatusNotFound, codeAttendeeNotFound, "You are not attending this event")
+		return
+	}
+
+	c.JSON(http.StatusOK, eventRemindersResponse{Enabled: *request.Enabled})
+}
+
+// sendRemindersJob sends the reminders that are due. It is queued every minute.
+var sendRemindersJob = jobs.NewType[struct{}]("send_reminders")
+
+// sendReminders is the handler of sendRemindersJob. It reminds attendees of published
+// events at each of events.reminders before the event starts, which is the start of its
+// date in UTC.
+//
+// A reminder that came due while no instance was running is sent late, as long as the
+// event has not started. When several offsets are due at once, as after a long restart,
+// the attendee gets a single reminder.
+func (app *application) sendReminders(ctx context.Context, _ struct{}) error {
+	offsets := app.config.Events.ReminderOffsets()
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	sent, err := app.sendDueReminders(ctx, time.Now().UTC(), offsets)
+	if sent > 0 {
+		logging.FromContext(ctx).Info("sent reminders", "count", sent)
+	}
+	return err
+}
+
+// scheduleSendReminders runs until ctx is done, queueing a sendRemindersJob every interval
+// unless one is already queued, by this or another instance.
+func (app *application) scheduleSendReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := app.enqueueOnce(ctx, sendRemindersJob.Name(), func(ctx context.Context) (*database.Job, error) {
+			return sendRemindersJob.Enqueue(ctx, app.jobQueue, struct{}{})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to queue reminders", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDueReminders sends the reminders that are due at now and returns how many it sent.
+// offsets must be sorted, shortest first.
+func (app *application) sendDueReminders(ctx context.Context, now time.Time, offsets []time.Duration) (int, error) {
+	today := now.Truncate(24 * time.Hour)
+	until := now.Add(offsets[len(offsets)-1]).Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	reminders, err := app.models.Reminders.Upcoming(ctx, today.Format(time.DateOnly), until.Format(time.DateOnly))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		start, err := time.Parse(time.DateOnly, reminder.Event.Date[:10])
+		if err != nil || !now.Before(start) {
+			continue
+		}
+
+		var due []time.Duration
+		for _, offset := range offsets {
+			if !now.Before(start.Add(-offset)) {
+				due = append(due, offset)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		var claimed bool
+		err = app.models.Transact(ctx, func(ctx context.Context) error {
+			claimed, err = app.models.Reminders.Claim(ctx, reminder.Event.Id, reminder.User.Id, start.Format(time.DateOnly), due)
+			if err != nil || !claimed {
+				return err
+			}
+			return app.remind(ctx, reminder)
+		})
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		if err != nil {
+			// the reminder is tried again on the next run; the others still go out
+			logging.FromContext(ctx).Error("failed to send reminder", "event_id", reminder.Event.Id, "user_id", reminder.User.Id, "error", err)
+			continue
+		}
+		if claimed {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// remind notifies an attendee that an event is coming up and emails them, unless they
+// have turned reminder emails off.
+func (app *application) remind(ctx context.Context, reminder *database.Reminder) error {
+	event := reminder.Event
+
+	err := app.models.Notifications.Insert(ctx, &database.Notification{
+		UserId:  reminder.User.Id,
+		EventId: event.Id,
+		Type:    database.NotificationEventReminder,
+		Message: fmt.Sprintf("Reminder: %q is on %s", event.Name, event.Date[:10]),
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.queueEmail(ctx, reminder.User, database.EmailCategoryReminders, mail.TemplateReminder, eventEmailData(event, nil))
+}
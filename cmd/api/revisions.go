@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-event-crud/internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type revisionDiffResponse struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes []database.FieldChange `json:"changes"`
+}
+
+// recordEventRevision snapshots the current state of an event. Events created before
+// revisions existed have no history yet, so their previous state is stored first.
+func (app *application) recordEventRevision(ctx context.Context, previous, current *database.Event, userId int) error {
+	if previous != nil {
+		count, err := app.models.EventRevisions.Count(ctx, previous.Id)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			if _, err := app.models.EventRevisions.Insert(ctx, previous, previous.OwnerId); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := app.models.EventRevisions.Insert(ctx, current, userId)
+	return err
+}
+
+// getEventRevisions godoc
+//
+//	@Summary		List event revisions
+//	@Description	List every stored revision of an event, newest first (requires authentication and ownership)
+//	@Tags			revisions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{array}		database.EventRevision
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		403	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/revisions [get]
+func (app *application) getEventRevisions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to view this event's revisions")
+		return
+	}
+
+	revisions, err := app.models.EventRevisions.GetByEvent(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// getEventRevisionDiff godoc
+//
+//	@Summary		Diff two event revisions
+//	@Description	Show the field-level changes between two revisions of an event (requires authentication and ownership)
+//	@Tags			revisions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int	true	"Event ID"
+//	@Param			from	query		int	true	"Revision to diff from"
+//	@Param			to		query		int	true	"Revision to diff to"
+//	@Success		200		{object}	revisionDiffResponse
+//	@Failure		400		{object}	problem
+//	@Failure		401		{object}	problem
+//	@Failure		403		{object}	problem
+//	@Failure		404		{object}	problem
+//	@Failure		500		{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/revisions/diff [get]
+func (app *application) getEventRevisionDiff(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidRevision, "Invalid 'from' revision")
+		return
+	}
+
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidRevision, "Invalid 'to' revision")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to view this event's revisions")
+		return
+	}
+
+	fromRevision, err := app.models.EventRevisions.Get(c.Request.Context(), id, from)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	toRevision, err := app.models.EventRevisions.Get(c.Request.Context(), id, to)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if fromRevision == nil || toRevision == nil {
+		app.errorResponse(c, http.StatusNotFound, codeRevisionNotFound, "Revision not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, revisionDiffResponse{
+		From:    from,
+		To:      to,
+		Changes: fromRevision.Diff(toRevision),
+	})
+}
+
+// restoreEventRevision godoc
+//
+//	@Summary		Restore an event revision
+//	@Description	Restore an old revision of an event; the restored state is saved as a new revision (requires authentication and ownership)
+//	@Tags			revisions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Event ID"
+//	@Param			revision	path		int		true	"Revision number"
+//	@Param			If-Match	header		string	true	"ETag of the event being restored"
+//	@Success		200			{object}	database.Event
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		412			{object}	problem
+//	@Failure		428			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/revisions/{revision}/restore [post]
+func (app *application) restoreEventRevision(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	revisionNumber, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidRevision, "Invalid revision")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	existingEvent, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if existingEvent == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if existingEvent.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to update this event")
+		return
+	}
+
+	if !app.checkIfMatch(c, existingEvent) {
+		return
+	}
+
+	revision, err := app.models.EventRevisions.Get(c.Request.Context(), id, revisionNumber)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if revision == nil {
+		app.errorResponse(c, http.StatusNotFound, codeRevisionNotFound, "Revision not found")
+		return
+	}
+
+	restored := revision.Event(existingEvent.OwnerId)
+	restored.Status = existingEvent.Status
+	restored.StatusReason = existingEvent.StatusReason
+	restored.StatusChangedAt = existingEvent.StatusChangedAt
+	restored.PublishAt = existingEvent.PublishAt
+
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		restored.Version = existingEvent.Version
+
+		if err := app.models.Events.Update(ctx, restored); err != nil {
+			if errors.Is(err, database.ErrEditConflict) {
+				return errPreconditionFailed
+			}
+			return err
+		}
+
+		if err := app.recordEventRevision(ctx, existingEvent, restored, user.Id); err != nil {
+			return err
+		}
+
+		return app.notifyEventUpdated(ctx, existingEvent, restored)
+	})
+	if err != nil {
+		app.handleError(c, err)
+		return
+	}
+
+	c.Header("ETag", eventETag(restored))
+	c.JSON(http.StatusOK, restored)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go-event-crud/internal/database"
+)
+
+func TestRestoreEventRevisionRequiresIfMatch(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+
+	event := ts.createEvent(t, token)
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+
+	body := newEventBody()
+	body["location"] = "Somewhere else"
+	if rec := ts.request(t, http.MethodPut, path, token, body, "If-Match", `"1"`); rec.Code != http.StatusOK {
+		t.Fatalf("update event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	restore := path + "/revisions/1/restore"
+	tests := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"without If-Match", nil, http.StatusPreconditionRequired},
+		{"with a stale ETag", []string{"If-Match", `"1"`}, http.StatusPreconditionFailed},
+		{"with a weak ETag", []string{"If-Match", `W/"2"`}, http.StatusPreconditionFailed},
+		{"with the current ETag", []string{"If-Match", `"2"`}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if rec := ts.request(t, http.MethodPost, restore, token, nil, tt.headers...); rec.Code != tt.want {
+			t.Fatalf("restore %s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec := ts.request(t, http.MethodGet, path, token, nil)
+	var restored database.Event
+	decode(t, rec, &restored)
+	if restored.Location != "Somewhere nice" || restored.Version != 3 {
+		t.Fatalf("event = %+v, want revision 1 restored at version 3", restored)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"go-event-crud/internal/database"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,30 +10,90 @@ import (
 )
 
 func (app *application) routes() http.Handler {
-	g := gin.Default()
+	useJSONFieldNames()
+
+	g := gin.New()
+	// X-Forwarded-For is only believed from the configured proxies, so that clients cannot
+	// pick the IP they are rate limited and logged by. The proxies were validated with the
+	// configuration.
+	g.SetTrustedProxies(app.config.Server.TrustedProxies)
+	// tracing, logging and metrics wrap recovery so requests that panic are recorded as 500s
+	g.Use(app.TracingMiddleware(), app.RequestIDMiddleware(), app.AccessLogMiddleware(), app.MetricsMiddleware(), gin.CustomRecovery(app.recoverPanic))
+
+	g.HandleMethodNotAllowed = true
+	g.NoRoute(func(c *gin.Context) {
+		app.errorResponse(c, http.StatusNotFound, codeRouteNotFound, "The requested resource could not be found")
+	})
+	g.NoMethod(func(c *gin.Context) {
+		app.errorResponse(c, http.StatusMethodNotAllowed, codeMethodNotAllowed, "The method is not supported for this resource")
+	})
 
 	// Swagger endpoint
 	g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Liveness and readiness checks for load balancers and orchestrators
+	g.GET("/healthz", app.healthz)
+	g.GET("/readyz", app.readyz)
+
+	// Prometheus metrics
+	g.GET("/metrics", app.metricsHandler())
+
 	v1 := g.Group("/api/v1")
 	{
-		v1.GET("/events", app.getAllEvents)
-		v1.GET("/events/:id", app.getEventById)
-		v1.GET("/events/:id/attendees", app.getAttendeesForEvent)
-		v1.GET("/attendees/:id/events", app.getEventsByAttendee)
+		v1.GET("/events", app.OptionalAuthMiddleware(), app.RateLimitMiddleware("public"), app.getAllEvents)
+		v1.GET("/events/:id", app.OptionalAuthMiddleware(), app.RateLimitMiddleware("public"), app.getEventById)
+		v1.GET("/events/:id/status", app.OptionalAuthMiddleware(), app.RateLimitMiddleware("public"), app.getEventStatus)
+		v1.GET("/events/:id/attendees", app.OptionalAuthMiddleware(), app.RateLimitMiddleware("public"), app.getAttendeesForEvent)
+		v1.GET("/attendees/:id/events", app.RateLimitMiddleware("public"), app.getEventsByAttendee)
+
+		v1.POST("/register", app.RateLimitMiddleware("auth"), app.registerUser)
+		v1.POST("/login", app.RateLimitMiddleware("auth"), app.login)
+		v1.POST("/password-reset", app.RateLimitMiddleware("auth"), app.requestPasswordReset)
+		v1.POST("/password-reset/confirm", app.RateLimitMiddleware("auth"), app.confirmPasswordReset)
 
-		v1.POST("/register", app.registerUser)
-		v1.POST("/login", app.login)
+		v1.GET("/unsubscribe", app.RateLimitMiddleware("public"), app.confirmUnsubscribe)
+		v1.POST("/unsubscribe", app.RateLimitMiddleware("public"), app.unsubscribe)
 	}
 
 	authGroup := v1.Group("/")
-	authGroup.Use(app.AuthMiddleware())
+	authGroup.Use(app.AuthMiddleware(), app.RateLimitMiddleware("user"), app.IdempotencyMiddleware())
 	{
-		authGroup.POST("/events", app.createEvent)
+		authGroup.POST("/events", app.RateLimitMiddleware("create_event"), app.createEvent)
 		authGroup.PUT("/events/:id", app.updateEvent)
+		authGroup.PATCH("/events/:id", app.patchEvent)
 		authGroup.DELETE("/events/:id", app.deleteEvent)
+		authGroup.GET("/events/trash", app.getTrashedEvents)
+		authGroup.POST("/events/:id/restore", app.restoreEvent)
+		authGroup.POST("/events/:id/publish", app.transitionEvent(database.EventStatusPublished))
+		authGroup.POST("/events/:id/cancel", app.transitionEvent(database.EventStatusCancelled))
+		authGroup.POST("/events/:id/postpone", app.transitionEvent(database.EventStatusPostponed))
+		authGroup.POST("/events/:id/complete", app.transitionEvent(database.EventStatusCompleted))
+		authGroup.PUT("/events/:id/schedule", app.scheduleEvent)
 		authGroup.POST("/events/:id/attendees/:userId", app.addAttendeeToEvent)
+		authGroup.POST("/events/:id/attendees/batch", app.batchUpdateAttendees)
 		authGroup.DELETE("/events/:id/attendees/:userId", app.deleteAttendeeFromEvent)
+		authGroup.GET("/events/:id/reminders", app.getEventReminders)
+		authGroup.PUT("/events/:id/reminders", app.updateEventReminders)
+
+		authGroup.GET("/events/:id/revisions", app.getEventRevisions)
+		authGroup.GET("/events/:id/revisions/diff", app.getEventRevisionDiff)
+		authGroup.POST("/events/:id/revisions/:revision/restore", app.restoreEventRevision)
+
+		authGroup.GET("/notifications", app.getNotifications)
+		authGroup.POST("/notifications/read", app.markAllNotificationsRead)
+		authGroup.POST("/notifications/:id/read", app.markNotificationRead)
+
+		authGroup.GET("/email-preferences", app.getEmailPreferences)
+		authGroup.PUT("/email-preferences", app.updateEmailPreferences)
+	}
+
+	adminGroup := v1.Group("/admin")
+	adminGroup.Use(app.AuthMiddleware(), app.RateLimitMiddleware("user"), app.AdminMiddleware())
+	{
+		adminGroup.POST("/backup", app.createBackup)
+		adminGroup.GET("/jobs", app.getJobs)
+		adminGroup.GET("/jobs/:id", app.getJob)
+		adminGroup.POST("/jobs/:id/retry", app.retryJob)
 	}
 
 	return g
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/logging"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type scheduleRequest struct {
+	PublishAt *time.Time `json:"publishAt"`
+}
+
+// scheduleEvent godoc
+//
+//	@Summary		Schedule a draft for publishing
+//	@Description	Set the time at which a draft event is published automatically, or send a null publishAt to clear it (requires authentication and ownership)
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Event ID"
+//	@Param			schedule	body		scheduleRequest	true	"Publish time"
+//	@Success		200			{object}	database.Event
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		409			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/schedule [put]
+func (app *application) scheduleEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	var request scheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		app.validationErrorResponse(c, err)
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to update this event")
+		return
+	}
+
+	if event.Status != database.EventStatusDraft {
+		app.errorResponse(c, http.StatusConflict, codeEventNotDraft, "Only draft events can be scheduled")
+		return
+	}
+
+	ok, err := app.models.Events.Schedule(c.Request.Context(), event, request.PublishAt)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if !ok {
+		app.errorResponse(c, http.StatusConflict, codeEventNotDraft, "Only draft events can be scheduled")
+		return
+	}
+
+	app.wakePublisher()
+
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
+
+// wakePublisher makes the publisher re-read the schedule, so a newly scheduled draft
+// that is due before the next poll is not published late.
+func (app *application) wakePublisher() {
+	select {
+	case app.publishWake <- struct{}{}:
+	default:
+	}
+}
+
+// publishScheduled runs until ctx is done, publishing drafts when their publish time
+// arrives. It sleeps until the next scheduled draft is due, but never longer than interval.
+func (app *application) publishScheduled(ctx context.Context, interval time.Duration) {
+	for {
+		wait := interval
+
+		ids, err := app.models.Events.PublishDue(ctx, time.Now())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to publish scheduled events", "error", err)
+		} else {
+			if len(ids) > 0 {
+				logging.FromContext(ctx).Info("published scheduled events", "event_ids", ids)
+			}
+
+			next, err := app.models.Events.NextPublishAt(ctx)
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to read publishing schedule", "error", err)
+			} else if next != nil && time.Until(*next) < wait {
+				wait = max(time.Until(*next), 0)
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-app.publishWake:
+			timer.Stop()
+		}
+	}
+}
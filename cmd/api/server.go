@@ -1,22 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+// serve starts the application and runs it until it receives SIGINT or SIGTERM, or the
+// server fails. It then fails readiness checks for the shutdown delay, stops taking new
+// connections, lets in-flight requests finish within the shutdown timeout, stops the
+// workers and closes the database, in that order.
 func (app *application) serve() error {
-	server := http.Server {
-		Addr: fmt.Sprintf(":%d",app.port),
-		Handler: app.routes(),
-		IdleTimeout: time.Minute,
-		ReadTimeout: 10 * time.Minute,
-		WriteTimeout: 30 * time.Minute,
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.Server.Port),
+		Handler:      app.routes(),
+		IdleTimeout:  app.config.Server.IdleTimeout,
+		ReadTimeout:  app.config.Server.ReadTimeout,
+		WriteTimeout: app.config.Server.WriteTimeout,
 	}
 
-	log.Printf("Starting server on %s", server.Addr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return server.ListenAndServe()
-} 
\ No newline at end of file
+	serverErr := make(chan error, 1)
+
+	lc := lifecycle{logger: app.logger}
+	lc.add(component{
+		// stopped last, so spans recorded while the rest shuts down are flushed
+		name: "tracing",
+		stop: app.stopTracing,
+	})
+	lc.add(component{
+		name: "database",
+		start: func(ctx context.Context) error {
+			return app.db.PingContext(ctx)
+		},
+		stop: func(context.Context) error {
+			return app.db.Close()
+		},
+	})
+	lc.add(component{
+		name:  "workers",
+		start: app.startWorkers,
+		stop:  app.stopWorkers,
+	})
+	lc.add(component{
+		name: "http server",
+		start: func(context.Context) error {
+			// Listening before serving makes a taken port fail startup instead of the
+			// serving goroutine.
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+
+			app.logger.Info("starting server", "addr", server.Addr)
+			go func() {
+				if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+					serverErr <- err
+				}
+			}()
+			return nil
+		},
+		stop: server.Shutdown,
+	})
+
+	if err := lc.start(ctx); err != nil {
+		return err
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		app.logger.Info("shutting down, waiting for in-flight requests", "timeout", app.config.Server.ShutdownTimeout.String())
+	case err = <-serverErr:
+	}
+
+	// Readiness checks that are still being served while the server drains report it as
+	// shutting down.
+	app.shuttingDown.Store(true)
+
+	// Keep serving for a while after failing readiness, so load balancers see it and stop
+	// sending new requests before the listener closes.
+	if err == nil && app.config.Server.ShutdownDelay > 0 {
+		app.logger.Info("waiting for load balancers to stop sending requests", "delay", app.config.Server.ShutdownDelay.String())
+		time.Sleep(app.config.Server.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.Server.ShutdownTimeout)
+	defer cancel()
+
+	if stopErr := lc.stop(shutdownCtx); stopErr != nil {
+		return errors.Join(err, stopErr)
+	}
+	if err == nil {
+		app.logger.Info("stopped")
+	}
+	return err
+}
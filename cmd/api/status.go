@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/mail"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type transitionRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+	Date   string `json:"date" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type eventStatusResponse struct {
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason,omitempty"`
+	ChangedAt          *time.Time `json:"changedAt,omitempty"`
+	PublishAt          *time.Time `json:"publishAt,omitempty"`
+	AllowedTransitions []string   `json:"allowedTransitions"`
+}
+
+// sameDay reports whether two event dates fall on the same day. Dates are bound as
+// YYYY-MM-DD but read back from SQLite as full timestamps.
+func sameDay(a, b string) bool {
+	return len(a) >= 10 && len(b) >= 10 && a[:10] == b[:10]
+}
+
+// getEventStatus godoc
+//
+//	@Summary		Get the status of an event
+//	@Description	Get the lifecycle status of an event, the reason for the last change and the statuses it can move to
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	eventStatusResponse
+//	@Failure		400	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Router			/events/{id}/status [get]
+func (app *application) getEventStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	event, err := app.models.Events.GetById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if event == nil || !event.VisibleTo(app.GetUserFromContext(c).Id) {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, eventStatusResponse{
+		Status:             event.Status,
+		Reason:             event.StatusReason,
+		ChangedAt:          event.StatusChangedAt,
+		PublishAt:          event.PublishAt,
+		AllowedTransitions: event.AllowedTransitions(),
+	})
+}
+
+// transitionEvent godoc
+//
+//	@Summary		Change the status of an event
+//	@Description	Publish, cancel, postpone or complete an event (requires authentication and ownership).
+//	@Description	Cancelling and postponing require a reason; postponing or re-publishing may move the event to a new date.
+//	@Description	Attendees are notified when an event is cancelled, postponed or rescheduled.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Event ID"
+//	@Param			transition	body		transitionRequest	false	"Reason and new date"
+//	@Success		200			{object}	database.Event
+//	@Failure		400			{object}	problem
+//	@Failure		401			{object}	problem
+//	@Failure		403			{object}	problem
+//	@Failure		404			{object}	problem
+//	@Failure		409			{object}	problem
+//	@Failure		500			{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/publish [post]
+//	@Router			/events/{id}/cancel [post]
+//	@Router			/events/{id}/postpone [post]
+//	@Router			/events/{id}/complete [post]
+func (app *application) transitionEvent(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+			return
+		}
+
+		var request transitionRequest
+		if err := c.ShouldBindJSON(&request); err != nil && err != io.EOF {
+			app.validationErrorResponse(c, err)
+			return
+		}
+
+		if request.Reason == "" && (status == database.EventStatusCancelled || status == database.EventStatusPostponed) {
+			app.errorResponse(c, http.StatusBadRequest, codeReasonRequired, "A reason is required")
+			return
+		}
+
+		if request.Date != "" && status != database.EventStatusPostponed && status != database.EventStatusPublished {
+			app.errorResponse(c, http.StatusBadRequest, codeDateNotAllowed, "A new date can only be set when postponing or publishing")
+			return
+		}
+
+		user := app.GetUserFromContext(c)
+		event, err := app.models.Events.GetById(c.Request.Context(), id)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+
+		if event == nil {
+			app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found")
+			return
+		}
+
+		if event.OwnerId != user.Id {
+			app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to update this event")
+			return
+		}
+
+		if !event.CanTransitionTo(status) {
+			app.errorResponse(c, http.StatusConflict, codeInvalidTransition, fmt.Sprintf("Cannot move a %s event to %s", event.Status, status))
+			return
+		}
+
+		// Transition updates the event it is given, so each attempt works on a fresh copy.
+		var updated database.Event
+		err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+			updated = *event
+
+			ok, err := app.models.Events.Transition(ctx, &updated, status, request.Reason, request.Date)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return &apiError{Status: http.StatusConflict, Code: codeEditConflict, Detail: "Event status was changed by another request"}
+			}
+
+			return app.notifyStatusChange(ctx, &updated, event.Status, event.Date)
+		})
+		if err != nil {
+			app.handleError(c, err)
+			return
+		}
+
+		c.Header("ETag", eventETag(&updated))
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// notifyStatusChange queues a notification for every attendee when an event has just been
+// cancelled or postponed, or has been moved to a different date. Attendees of a cancelled
+// event are emailed too.
+func (app *application) notifyStatusChange(ctx context.Context, event *database.Event, previousStatus, previousDate string) error {
+	var notificationType, message string
+
+	statusChanged := event.Status != previousStatus
+
+	switch {
+	case statusChanged && event.Status == database.EventStatusCancelled:
+		notificationType = database.NotificationEventCancelled
+		message = fmt.Sprintf("%q has been cancelled: %s", event.Name, event.StatusReason)
+	case statusChanged && event.Status == database.EventStatusPostponed:
+		notificationType = database.NotificationEventPostponed
+		message = fmt.Sprintf("%q has been postponed: %s", event.Name, event.StatusReason)
+		if !sameDay(event.Date, previousDate) {
+			message = fmt.Sprintf("%q has been postponed to %s: %s", event.Name, event.Date[:10], event.StatusReason)
+		}
+	case !sameDay(event.Date, previousDate):
+		notificationType = database.NotificationEventRescheduled
+		message = fmt.Sprintf("%q has been rescheduled to %s", event.Name, event.Date[:10])
+	default:
+		return nil
+	}
+
+	_, err := app.models.Notifications.InsertForAttendees(ctx, event.Id, notificationType, message)
+	if err != nil || notificationType != database.NotificationEventCancelled {
+		return err
+	}
+
+	return app.emailAttendees(ctx, event, database.EmailCategoryCancellations, mail.TemplateCancellation, map[string]any{
+		"Reason": event.StatusReason,
+	})
+}
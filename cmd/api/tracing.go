@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+)
+
+// tracer records the spans handlers add inside a request's span, such as password hashing
+// and token checks.
+var tracer = otel.Tracer("go-event-crud/cmd/api")
+
+// TracingMiddleware starts a span named after the route for every request, continuing the
+// trace of an incoming traceparent header. Probes and metric scrapes are not traced.
+func (app *application) TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(app.config.Tracing.ServiceName,
+		otelgin.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				return false
+			}
+			return true
+		}),
+	)
+}
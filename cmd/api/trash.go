@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/jobs"
+	"go-event-crud/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getTrashedEvents godoc
+//
+//	@Summary		List trashed events
+//	@Description	List the authenticated user's deleted events that have not been purged yet
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		database.Event
+//	@Failure		401	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/trash [get]
+func (app *application) getTrashedEvents(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	events, err := app.models.Events.GetDeletedByOwner(c.Request.Context(), user.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// restoreEvent godoc
+//
+//	@Summary		Restore a deleted event
+//	@Description	Move an event out of the trash (requires authentication and ownership)
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	database.Event
+//	@Failure		400	{object}	problem
+//	@Failure		401	{object}	problem
+//	@Failure		403	{object}	problem
+//	@Failure		404	{object}	problem
+//	@Failure		500	{object}	problem
+//	@Security		BearerAuth
+//	@Router			/events/{id}/restore [post]
+func (app *application) restoreEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, http.StatusBadRequest, codeInvalidEventId, "Invalid event ID")
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	trashedEvent, err := app.models.Events.GetDeletedById(c.Request.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if trashedEvent == nil {
+		app.errorResponse(c, http.StatusNotFound, codeEventNotFound, "Event not found in trash")
+		return
+	}
+
+	if trashedEvent.OwnerId != user.Id {
+		app.errorResponse(c, http.StatusForbidden, codeNotEventOwner, "You are not authorized to restore this event")
+		return
+	}
+
+	// Restoring bumps the version, so the event is read back for its new ETag.
+	var restored *database.Event
+	err = app.models.Transact(c.Request.Context(), func(ctx context.Context) error {
+		if err := app.models.Events.Restore(ctx, id); err != nil {
+			if errors.Is(err, database.ErrRecordNotFound) {
+				return &apiError{Status: http.StatusNotFound, Code: codeEventNotFound, Detail: "Event not found in trash"}
+			}
+			return err
+		}
+
+		var err error
+		restored, err = app.models.Events.GetById(ctx, id)
+		return err
+	})
+	if err != nil {
+		app.handleError(c, err)
+		return
+	}
+
+	c.Header("ETag", eventETag(restored))
+	c.JSON(http.StatusOK, restored)
+}
+
+// purgeTrashJob permanently removes events that have been in the trash for longer than
+// the retention period.
+var purgeTrashJob = jobs.NewType[struct{}]("purge_trash")
+
+// purgeTrash is the handler of purgeTrashJob.
+func (app *application) purgeTrash(ctx context.Context, _ struct{}) error {
+	purged, err := app.models.Events.Purge(ctx, time.Now().Add(-app.config.Events.TrashRetention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		logging.FromContext(ctx).Info("purged trashed events", "count", purged)
+	}
+	return nil
+}
+
+// schedulePurgeTrash runs until ctx is done, queueing a purgeTrashJob every interval
+// unless one is already queued, by this or another instance.
+func (app *application) schedulePurgeTrash(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := app.enqueueOnce(ctx, purgeTrashJob.Name(), func(ctx context.Context) (*database.Job, error) {
+			return purgeTrashJob.Enqueue(ctx, app.jobQueue, struct{}{})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to queue trash purge", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go-event-crud/internal/database"
+)
+
+func TestPurgeTrashJob(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.config.Events.TrashRetention = 0
+	_, token := ts.signUp(t, "owner")
+
+	event := ts.createEvent(t, token)
+	rec := ts.request(t, http.MethodDelete, fmt.Sprintf("/api/v1/events/%d", event.Id), token, nil, "If-Match", `"1"`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	ctx := context.Background()
+	enqueue := func(ctx context.Context) (*database.Job, error) {
+		return purgeTrashJob.Enqueue(ctx, ts.app.jobQueue, struct{}{})
+	}
+
+	job, err := ts.app.enqueueOnce(ctx, purgeTrashJob.Name(), enqueue)
+	if err != nil || job == nil || job.Type != "purge_trash" {
+		t.Fatalf("enqueueOnce = %+v, %v; want a purge_trash job", job, err)
+	}
+
+	if again, err := ts.app.enqueueOnce(ctx, purgeTrashJob.Name(), enqueue); again != nil || err != nil {
+		t.Fatalf("enqueueOnce(already queued) = %+v, %v; want nil, nil", again, err)
+	}
+
+	if err := ts.app.purgeTrash(ctx, struct{}{}); err != nil {
+		t.Fatalf("purgeTrash: %v", err)
+	}
+
+	trashed, err := ts.app.models.Events.GetDeletedById(ctx, event.Id)
+	if err != nil || trashed != nil {
+		t.Fatalf("GetDeletedById = %+v, %v; want the event purged", trashed, err)
+	}
+}
+
+func TestRestoreEventReturnsNewVersion(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signUp(t, "owner")
+
+	event := ts.createEvent(t, token)
+	path := fmt.Sprintf("/api/v1/events/%d", event.Id)
+
+	rec := ts.request(t, http.MethodDelete, path, token, nil, "If-Match", `"1"`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = ts.request(t, http.MethodPost, path+"/restore", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore event: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var restored database.Event
+	decode(t, rec, &restored)
+	etag := rec.Header().Get("ETag")
+	if etag != eventETag(&restored) || restored.DeletedAt != nil {
+		t.Fatalf("restored event = %+v with ETag %s; want it restored with a matching ETag", restored, etag)
+	}
+
+	rec = ts.request(t, http.MethodGet, path, token, nil)
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Fatalf("GET ETag = %s, want %s as returned by the restore", got, etag)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go-event-crud/internal/logging"
+)
+
+// startWorkers starts the background workers. They run until stopWorkers is called, not
+// until ctx is done, so they keep running while the server drains.
+func (app *application) startWorkers(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.cancelWorkers = cancel
+
+	app.runWorker(ctx, "schedule_purge_trash", app.schedulePurgeTrash, time.Hour)
+	app.runWorker(ctx, "publish_scheduled", app.publishScheduled, time.Minute)
+	app.runWorker(ctx, "purge_idempotency_keys", app.purgeIdempotencyKeys, time.Hour)
+	app.runWorker(ctx, "run_jobs", app.jobQueue.Run, time.Minute)
+	app.runWorker(ctx, "purge_jobs", app.purgeJobs, time.Hour)
+	if len(app.config.Events.ReminderOffsets()) > 0 {
+		app.runWorker(ctx, "schedule_send_reminders", app.scheduleSendReminders, time.Minute)
+	}
+	return nil
+}
+
+// runWorker runs worker in a goroutine with a logger that names it in its context.
+func (app *application) runWorker(ctx context.Context, name string, worker func(ctx context.Context, interval time.Duration), interval time.Duration) {
+	ctx = logging.NewContext(ctx, app.logger.With("worker", name))
+
+	app.workers.Add(1)
+	go func() {
+		defer app.workers.Done()
+		worker(ctx, interval)
+	}()
+}
+
+// stopWorkers cancels the workers, which abandons any database operation they are in the
+// middle of, and waits for them to return or for ctx to be done.
+func (app *application) stopWorkers(ctx context.Context) error {
+	app.cancelWorkers()
+
+	done := make(chan struct{})
+	go func() {
+		app.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"go-event-crud/internal/config"
+	"go-event-crud/internal/database"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+func main() {
+	// reads the database to back up from the same configuration as the API
+	cfg := config.Default()
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	if err := cfg.Load(flags, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+
+	// opens the configured database, SQLite by default
+	db, _, err := database.Open(cfg.Database.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// the optional argument is the destination file, otherwise a timestamped file in the
+	// backup directory
+	dest := database.BackupPath(cfg.Backup.Dir, time.Now())
+	if flags.NArg() > 0 {
+		dest = flags.Arg(0)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Backup(context.Background(), db, dest); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("backed up database to %s", dest)
+}
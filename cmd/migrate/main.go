@@ -1,59 +1,180 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"github.com/golang-migrate/migrate/v4/database/sqlite3"
-	"github.com/golang-migrate/migrate/v4/source/file"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/joho/godotenv/autoload"
+	"go-event-crud/internal/config"
+	"go-event-crud/internal/database"
 )
 
+// migrationsDir is where create writes new migrations, relative to the repository root.
+const migrationsDir = "internal/database/migrations"
+
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up            apply all pending migrations
+  down          roll back all migrations
+  version       print the current migration version
+  goto N        migrate up or down to version N
+  steps N       apply the next N migrations, or roll back N if N is negative
+  force N       set the version to N and clear the dirty flag without running migrations
+  create NAME   create empty up and down migrations for every dialect
+
+flags are the configuration flags of the API, such as -config or -database-url`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Please provide a migration direction: 'up' or 'down'")
+	cfg := config.Default()
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	if err := cfg.Load(flags, os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
 
-	// Args[0] is migrate, Args[1] is the direction
-	direction := os.Args[1]
+	args := flags.Args()
+	if len(args) < 1 {
+		log.Fatal(usage)
+	}
+	command := args[0]
 
-	// opens sqlite db
-	db, err := sql.Open("sqlite3", "./data.db")
-	if err != nil {
-		log.Fatal(err)
+	// create only writes files, so it does not need a database
+	if command == "create" {
+		if len(args) < 2 {
+			log.Fatal("Please provide a migration name: migrate create NAME")
+		}
+		if err := create(args[1]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	defer db.Close()
 
-	// this starts an instance of the sqlite db with default configuration
-	instance, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	db, dialect, err := database.Open(cfg.Database.URL)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// opens the migrations directory
-	fSrc, err := (&file.File{}).Open("cmd/migrate/migrations")
+	// Closing the migrator closes the database too.
+	m, err := database.NewMigrator(db, dialect)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer m.Close()
 
-	// creates a new migration instance with the file source and sqlite instance
-	m, err := migrate.NewWithInstance("file", fSrc, "sqlite3", instance)
-	if err != nil {
+	if err := run(m, command, args[1:]); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		m.Close()
 		log.Fatal(err)
 	}
 
-	switch direction {
-	// if up, apply migrations
+	if command != "version" {
+		printVersion(m)
+	}
+}
+
+func run(m *migrate.Migrate, command string, args []string) error {
+	switch command {
 	case "up":
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
-		}
-	// if down, rollback migrations
+		return m.Up()
 	case "down":
-		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+		return m.Down()
+	case "version":
+		printVersion(m)
+		return nil
+	case "goto":
+		n, err := intArg(command, args)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("goto needs a version of 0 or more")
+		}
+		return m.Migrate(uint(n))
+	case "steps":
+		n, err := intArg(command, args)
+		if err != nil {
+			return err
 		}
+		return m.Steps(n)
+	case "force":
+		n, err := intArg(command, args)
+		if err != nil {
+			return err
+		}
+		return m.Force(n)
 	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'.")
+		return errors.New("unknown command " + command + "\n\n" + usage)
+	}
+}
+
+// printVersion prints the version the database is at and whether a failed migration left
+// it dirty.
+func printVersion(m *migrate.Migrate) {
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Println("no migrations applied")
+	case err != nil:
+		log.Print(err)
+	case dirty:
+		fmt.Printf("version %d (dirty)\n", version)
+	default:
+		fmt.Printf("version %d\n", version)
+	}
+}
+
+// intArg parses the numeric argument of a command.
+func intArg(command string, args []string) (int, error) {
+	if len(args) < 1 {
+		return 0, fmt.Errorf("Please provide a number: migrate %s N", command)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q for %s", args[0], command)
+	}
+	return n, nil
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// create writes empty up and down migrations with the next free version number for every
+// dialect, so the dialects stay in step.
+func create(name string) error {
+	if !migrationName.MatchString(name) {
+		return errors.New("migration names may only contain lowercase letters, digits and underscores")
+	}
+
+	dialects := []database.Dialect{database.SQLite, database.Postgres}
+
+	next := 1
+	for _, dialect := range dialects {
+		entries, err := os.ReadDir(filepath.Join(migrationsDir, string(dialect)))
+		if err != nil {
+			return fmt.Errorf("%w (run create from the repository root)", err)
+		}
+		for _, entry := range entries {
+			prefix, _, _ := strings.Cut(entry.Name(), "_")
+			if version, err := strconv.Atoi(prefix); err == nil && version >= next {
+				next = version + 1
+			}
+		}
+	}
+
+	for _, dialect := range dialects {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(migrationsDir, string(dialect), fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				return err
+			}
+			log.Printf("created %s", path)
+		}
 	}
-}
\ No newline at end of file
+	return nil
+}
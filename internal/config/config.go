@@ -0,0 +1,665 @@
+// Package config loads the application's configuration from, in increasing order of
+// precedence, built-in defaults, a YAML or TOML file, environment variables and
+// command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/ratelimit"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultJWTSecret is the JWT secret used when none is configured. It is only fit for
+// local development.
+const DefaultJWTSecret = "random-secret"
+
+type Config struct {
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Events    EventsConfig
+	Backup    BackupConfig
+	Tracing   TracingConfig
+	Log       LogConfig
+	RateLimit RateLimitConfig
+	Mail      MailConfig
+	Jobs      JobsConfig
+}
+
+type ServerConfig struct {
+	Port            int
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+	TrustedProxies  []string
+}
+
+type DatabaseConfig struct {
+	URL             string
+	AutoMigrate     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	QueryTimeout    time.Duration
+	BatchTimeout    time.Duration
+	SlowQuery       time.Duration
+}
+
+type AuthConfig struct {
+	JWTSecret        string
+	TokenLifetime    time.Duration
+	AdminEmails      []string
+	PasswordResetTTL time.Duration
+}
+
+type EventsConfig struct {
+	TrashRetention    time.Duration
+	IdempotencyKeyTTL time.Duration
+	Reminders         []string
+}
+
+// ReminderOffsets returns how long before an event starts its attendees are reminded of
+// it, shortest first. Validate rejects offsets time.ParseDuration cannot parse.
+func (c EventsConfig) ReminderOffsets() []time.Duration {
+	offsets := make([]time.Duration, 0, len(c.Reminders))
+	for _, reminder := range c.Reminders {
+		offset, _ := time.ParseDuration(reminder)
+		offsets = append(offsets, offset)
+	}
+	slices.Sort(offsets)
+	return slices.Compact(offsets)
+}
+
+type BackupConfig struct {
+	Dir string
+}
+
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+// SlogLevel returns the configured level. Validate rejects levels it cannot parse.
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+// RateLimitConfig holds the rate limit policies, written as "<limit>/<period>" such as
+// "10/1m". Validate rejects policies ParsePolicy cannot parse.
+type RateLimitConfig struct {
+	Enabled     bool
+	Auth        string
+	Public      string
+	User        string
+	CreateEvent string
+}
+
+// rateLimitPolicies names the policies of RateLimitConfig, as in their keys.
+var rateLimitPolicies = []string{"auth", "public", "user", "create_event"}
+
+func (c RateLimitConfig) rate(name string) string {
+	switch name {
+	case "auth":
+		return c.Auth
+	case "public":
+		return c.Public
+	case "user":
+		return c.User
+	case "create_event":
+		return c.CreateEvent
+	}
+	return ""
+}
+
+// Policy returns the named policy, such as "auth" for RateLimitConfig.Auth.
+func (c RateLimitConfig) Policy(name string) ratelimit.Policy {
+	policy, _ := ratelimit.ParsePolicy(name, c.rate(name))
+	return policy
+}
+
+type JobsConfig struct {
+	Concurrency int
+	Lease       time.Duration
+	MaxAttempts int
+	Retention   time.Duration
+}
+
+// MailConfig holds the SMTP server emails are sent through. Without an SMTP address no
+// emails are queued.
+type MailConfig struct {
+	SMTPAddr         string
+	SMTPUsername     string
+	SMTPPassword     string
+	From             string
+	BaseURL          string
+	PasswordResetURL string
+	MaxAttempts      int
+}
+
+// Enabled reports whether emails are sent.
+func (c MailConfig) Enabled() bool {
+	return c.SMTPAddr != ""
+}
+
+// FromAddress returns the address emails are sent from. Validate rejects addresses it
+// cannot parse.
+func (c MailConfig) FromAddress() *mail.Address {
+	from, _ := mail.ParseAddress(c.From)
+	return from
+}
+
+type TracingConfig struct {
+	Enabled      bool
+	ServiceName  string
+	OTLPEndpoint string
+	File         string
+	SampleRatio  float64
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            6969,
+			ReadTimeout:     10 * time.Minute,
+			WriteTimeout:    30 * time.Minute,
+			IdleTimeout:     time.Minute,
+			ShutdownDelay:   5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			URL:             "./data.db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxIdleTime: 15 * time.Minute,
+			QueryTimeout:    3 * time.Second,
+			BatchTimeout:    30 * time.Second,
+			SlowQuery:       500 * time.Millisecond,
+		},
+		Auth: AuthConfig{
+			JWTSecret:        DefaultJWTSecret,
+			TokenLifetime:    72 * time.Hour,
+			PasswordResetTTL: time.Hour,
+		},
+		Events: EventsConfig{
+			TrashRetention:    30 * 24 * time.Hour,
+			IdempotencyKeyTTL: 24 * time.Hour,
+			Reminders:         []string{"24h", "1h"},
+		},
+		Backup: BackupConfig{
+			Dir: "./backups",
+		},
+		Tracing: TracingConfig{
+			ServiceName: "go-event-crud",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     true,
+			Auth:        "10/1m",
+			Public:      "120/1m",
+			User:        "600/1m",
+			CreateEvent: "30/1h",
+		},
+		Mail: MailConfig{
+			From:             "Go Event CRUD <no-reply@localhost>",
+			BaseURL:          "http://localhost:6969",
+			PasswordResetURL: "http://localhost:3000/reset-password",
+			MaxAttempts:      8,
+		},
+		Jobs: JobsConfig{
+			Concurrency: 4,
+			Lease:       time.Minute,
+			MaxAttempts: 5,
+			Retention:   7 * 24 * time.Hour,
+		},
+	}
+}
+
+// setting ties a field of Config to its key in the config file, its environment variable
+// and its flag. The flag is named after the key, with dots and underscores turned into
+// dashes.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value any
+	// unit is what a bare number means for a duration, so DB_QUERY_TIMEOUT_SECONDS=3 and
+	// query_timeout: 3s mean the same.
+	unit   time.Duration
+	redact func(string) string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "server.port", env: "PORT", value: &c.Server.Port, usage: "port the HTTP server listens on"},
+		{key: "server.read_timeout", env: "HTTP_READ_TIMEOUT_SECONDS", unit: time.Second, value: &c.Server.ReadTimeout, usage: "time allowed to read a request"},
+		{key: "server.write_timeout", env: "HTTP_WRITE_TIMEOUT_SECONDS", unit: time.Second, value: &c.Server.WriteTimeout, usage: "time allowed to write a response"},
+		{key: "server.idle_timeout", env: "HTTP_IDLE_TIMEOUT_SECONDS", unit: time.Second, value: &c.Server.IdleTimeout, usage: "time an idle keep-alive connection is kept open"},
+		{key: "server.shutdown_delay", env: "SHUTDOWN_DELAY_SECONDS", unit: time.Second, value: &c.Server.ShutdownDelay, usage: "time /readyz fails before the server stops taking connections on shutdown"},
+		{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT_SECONDS", unit: time.Second, value: &c.Server.ShutdownTimeout, usage: "time in-flight requests get to finish on shutdown"},
+		{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", value: &c.Server.TrustedProxies, usage: "comma-separated IPs or CIDRs of proxies whose X-Forwarded-For header is trusted"},
+
+		{key: "database.url", env: "DATABASE_URL", value: &c.Database.URL, redact: redactURL, usage: "SQLite path or postgres:// URL"},
+		{key: "database.auto_migrate", env: "AUTO_MIGRATE", value: &c.Database.AutoMigrate, usage: "apply pending migrations at startup"},
+		{key: "database.max_open_conns", env: "DB_MAX_OPEN_CONNS", value: &c.Database.MaxOpenConns, usage: "maximum number of open database connections"},
+		{key: "database.max_idle_conns", env: "DB_MAX_IDLE_CONNS", value: &c.Database.MaxIdleConns, usage: "maximum number of idle database connections"},
+		{key: "database.conn_max_idle_time", env: "DB_CONN_MAX_IDLE_MINUTES", unit: time.Minute, value: &c.Database.ConnMaxIdleTime, usage: "time an idle database connection is kept"},
+		{key: "database.query_timeout", env: "DB_QUERY_TIMEOUT_SECONDS", unit: time.Second, value: &c.Database.QueryTimeout, usage: "deadline for a single database operation"},
+		{key: "database.batch_timeout", env: "DB_BATCH_TIMEOUT_SECONDS", unit: time.Second, value: &c.Database.BatchTimeout, usage: "deadline for bulk database operations"},
+		{key: "database.slow_query", env: "DB_SLOW_QUERY_MS", unit: time.Millisecond, value: &c.Database.SlowQuery, usage: "database operations slower than this are logged"},
+
+		{key: "auth.jwt_secret", env: "JWT_SECRET", value: &c.Auth.JWTSecret, redact: redactSecret, usage: "secret JWTs are signed with"},
+		{key: "auth.token_lifetime", env: "TOKEN_LIFETIME_HOURS", unit: time.Hour, value: &c.Auth.TokenLifetime, usage: "time a login token stays valid"},
+		{key: "auth.admin_emails", env: "ADMIN_EMAILS", value: &c.Auth.AdminEmails, usage: "comma-separated emails of administrators"},
+		{key: "auth.password_reset_ttl", env: "PASSWORD_RESET_TTL_MINUTES", unit: time.Minute, value: &c.Auth.PasswordResetTTL, usage: "time a password reset link stays valid"},
+
+		{key: "events.trash_retention", env: "TRASH_RETENTION_DAYS", unit: 24 * time.Hour, value: &c.Events.TrashRetention, usage: "time a deleted event stays in the trash"},
+		{key: "events.idempotency_key_ttl", env: "IDEMPOTENCY_KEY_TTL_HOURS", unit: time.Hour, value: &c.Events.IdempotencyKeyTTL, usage: "time an idempotency key is kept"},
+		{key: "events.reminders", env: "EVENT_REMINDERS", value: &c.Events.Reminders, usage: "comma-separated durations before an event at which attendees are reminded of it"},
+
+		{key: "backup.dir", env: "BACKUP_DIR", value: &c.Backup.Dir, usage: "directory backups are written to"},
+
+		{key: "tracing.enabled", env: "TRACING_ENABLED", value: &c.Tracing.Enabled, usage: "record OpenTelemetry traces"},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", value: &c.Tracing.ServiceName, usage: "service name traces are reported under"},
+		{key: "tracing.otlp_endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", value: &c.Tracing.OTLPEndpoint, usage: "OTLP/HTTP collector URL; without one spans are written to tracing.file or stdout"},
+		{key: "tracing.file", env: "TRACING_FILE", value: &c.Tracing.File, usage: "file spans are written to when there is no OTLP endpoint"},
+		{key: "tracing.sample_ratio", env: "TRACING_SAMPLE_RATIO", value: &c.Tracing.SampleRatio, usage: "fraction of new traces that are recorded"},
+
+		{key: "log.level", env: "LOG_LEVEL", value: &c.Log.Level, usage: "lowest level logged: debug, info, warn or error"},
+		{key: "log.format", env: "LOG_FORMAT", value: &c.Log.Format, usage: "log format: json or text"},
+
+		{key: "rate_limit.enabled", env: "RATE_LIMIT_ENABLED", value: &c.RateLimit.Enabled, usage: "limit the rate of requests per client"},
+		{key: "rate_limit.auth", env: "RATE_LIMIT_AUTH", value: &c.RateLimit.Auth, usage: "rate of login and registration requests per IP"},
+		{key: "rate_limit.public", env: "RATE_LIMIT_PUBLIC", value: &c.RateLimit.Public, usage: "rate of requests to public routes per IP, or per user when signed in"},
+		{key: "rate_limit.user", env: "RATE_LIMIT_USER", value: &c.RateLimit.User, usage: "rate of requests to authenticated routes per user"},
+		{key: "rate_limit.create_event", env: "RATE_LIMIT_CREATE_EVENT", value: &c.RateLimit.CreateEvent, usage: "rate of event creation per user"},
+
+		{key: "mail.smtp_addr", env: "SMTP_ADDR", value: &c.Mail.SMTPAddr, usage: "host:port of the SMTP server emails are sent through; emails are off without one"},
+		{key: "mail.smtp_username", env: "SMTP_USERNAME", value: &c.Mail.SMTPUsername, usage: "SMTP username; no authentication without one"},
+		{key: "mail.smtp_password", env: "SMTP_PASSWORD", value: &c.Mail.SMTPPassword, redact: redactSecret, usage: "SMTP password"},
+		{key: "mail.from", env: "MAIL_FROM", value: &c.Mail.From, usage: "address emails are sent from"},
+		{key: "mail.base_url", env: "APP_BASE_URL", value: &c.Mail.BaseURL, usage: "public URL of the API, used in unsubscribe links"},
+		{key: "mail.password_reset_url", env: "PASSWORD_RESET_URL", value: &c.Mail.PasswordResetURL, usage: "page password reset links point to, given the token as ?token="},
+		{key: "mail.max_attempts", env: "MAIL_MAX_ATTEMPTS", value: &c.Mail.MaxAttempts, usage: "times an email is tried before it is given up on"},
+
+		{key: "jobs.concurrency", env: "JOB_CONCURRENCY", value: &c.Jobs.Concurrency, usage: "background jobs an instance runs at once"},
+		{key: "jobs.lease", env: "JOB_LEASE_SECONDS", unit: time.Second, value: &c.Jobs.Lease, usage: "time an instance holds a job before another may take it over"},
+		{key: "jobs.max_attempts", env: "JOB_MAX_ATTEMPTS", value: &c.Jobs.MaxAttempts, usage: "times a job is tried before it fails"},
+		{key: "jobs.retention", env: "JOB_RETENTION_DAYS", unit: 24 * time.Hour, value: &c.Jobs.Retention, usage: "time a finished job is kept"},
+	}
+}
+
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// Load registers a flag for every setting and a -config flag on fs, parses args, and then
+// applies the config file named by -config or CONFIG_FILE, the environment and the flags
+// on top of c, in that order. It validates the result. Arguments that are not flags are
+// left in fs.Args().
+func (c *Config) Load(fs *flag.FlagSet, args []string) error {
+	settings := c.settings()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML `file` to read the configuration from (env CONFIG_FILE)")
+
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if value := s.format(); value != "" {
+			usage = fmt.Sprintf("%s (default %s, env %s)", s.usage, value, s.env)
+		}
+		fs.Func(s.flagName(), usage, func(raw string) error {
+			flagValues = append(flagValues, flagValue{s, raw})
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFile != "" {
+		if err := c.loadFile(*configFile, settings); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range settings {
+		if raw, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(raw); err != nil {
+				return fmt.Errorf("environment variable %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, f := range flagValues {
+		if err := f.setting.set(f.raw); err != nil {
+			return fmt.Errorf("flag -%s: %w", f.setting.flagName(), err)
+		}
+	}
+
+	return c.Validate()
+}
+
+// loadFile applies a YAML or TOML file, picked by its extension. Its sections and keys are
+// those of the settings, such as database.url; unknown keys are an error so typos don't
+// go unnoticed.
+func (c *Config) loadFile(path string, settings []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	for key, raw := range values {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		if err := s.set(raw); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested sections into dotted keys with their values as strings, the form
+// environment variables and flags come in.
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch p := s.value.(type) {
+	case *string:
+		*p = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		*p = b
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		*p = f
+	case *time.Duration:
+		if n, err := strconv.Atoi(raw); err == nil {
+			*p = time.Duration(n) * s.unit
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 15m, or a number of %s", raw, unitName(s.unit))
+		}
+		*p = d
+	case *[]string:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*p = items
+	}
+	return nil
+}
+
+func unitName(unit time.Duration) string {
+	switch unit {
+	case time.Millisecond:
+		return "milliseconds"
+	case time.Second:
+		return "seconds"
+	case time.Minute:
+		return "minutes"
+	case time.Hour:
+		return "hours"
+	default:
+		return "days"
+	}
+}
+
+// format returns the setting's current value in the form it can be set in.
+func (s setting) format() string {
+	switch p := s.value.(type) {
+	case *string:
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *time.Duration:
+		return p.String()
+	case *[]string:
+		return strings.Join(*p, ",")
+	}
+	return ""
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, problem string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, problem))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port", "must be between 1 and 65535")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies", fmt.Sprintf("%q is not an IP address or CIDR", proxy))
+	}
+
+	if _, _, err := database.ParseDSN(c.Database.URL); c.Database.URL == "" {
+		check(false, "database.url", "must be set")
+	} else if err != nil {
+		check(false, "database.url", err.Error())
+	}
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns", "must be at least 1")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns", "must not exceed database.max_open_conns")
+	check(c.Database.ConnMaxIdleTime > 0, "database.conn_max_idle_time", "must be positive")
+	check(c.Database.QueryTimeout > 0, "database.query_timeout", "must be positive")
+	check(c.Database.BatchTimeout >= c.Database.QueryTimeout, "database.batch_timeout", "must be at least database.query_timeout")
+	check(c.Database.SlowQuery > 0, "database.slow_query", "must be positive")
+
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret", "must be set")
+	check(c.Auth.TokenLifetime > 0, "auth.token_lifetime", "must be positive")
+	check(c.Auth.PasswordResetTTL > 0, "auth.password_reset_ttl", "must be positive")
+	for _, email := range c.Auth.AdminEmails {
+		_, err := mail.ParseAddress(email)
+		check(err == nil, "auth.admin_emails", fmt.Sprintf("%q is not an email address", email))
+	}
+
+	check(c.Events.TrashRetention > 0, "events.trash_retention", "must be positive")
+	check(c.Events.IdempotencyKeyTTL > 0, "events.idempotency_key_ttl", "must be positive")
+	for _, reminder := range c.Events.Reminders {
+		offset, err := time.ParseDuration(reminder)
+		check(err == nil && offset >= time.Minute, "events.reminders", fmt.Sprintf("%q is not a duration of at least 1m", reminder))
+	}
+
+	check(c.Backup.Dir != "", "backup.dir", "must be set")
+
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "must be set")
+	if c.Tracing.OTLPEndpoint != "" {
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing.otlp_endpoint", "must be an http:// or https:// URL")
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error")
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "must be json or text")
+
+	for _, name := range rateLimitPolicies {
+		_, err := ratelimit.ParsePolicy(name, c.RateLimit.rate(name))
+		check(err == nil, "rate_limit."+name, fmt.Sprint(err))
+	}
+
+	if c.Mail.SMTPAddr != "" {
+		_, _, err := net.SplitHostPort(c.Mail.SMTPAddr)
+		check(err == nil, "mail.smtp_addr", "must be host:port")
+	}
+	_, err := mail.ParseAddress(c.Mail.From)
+	check(err == nil, "mail.from", "must be an email address")
+	for _, link := range []struct{ key, value string }{
+		{"mail.base_url", c.Mail.BaseURL},
+		{"mail.password_reset_url", c.Mail.PasswordResetURL},
+	} {
+		u, err := url.Parse(link.value)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", link.key, "must be an http:// or https:// URL")
+	}
+	check(c.Mail.MaxAttempts > 0, "mail.max_attempts", "must be at least 1")
+
+	check(c.Jobs.Concurrency > 0, "jobs.concurrency", "must be at least 1")
+	check(c.Jobs.Lease >= 3*time.Second, "jobs.lease", "must be at least 3s")
+	check(c.Jobs.MaxAttempts > 0, "jobs.max_attempts", "must be at least 1")
+	check(c.Jobs.Retention > 0, "jobs.retention", "must be positive")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Write prints the configuration as a YAML config file, with secrets redacted.
+func (c *Config) Write(w io.Writer) error {
+	section := ""
+	for _, s := range c.settings() {
+		name, key, _ := strings.Cut(s.key, ".")
+		if name != section {
+			if section != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", name)
+			section = name
+		}
+
+		value := s.format()
+		if s.redact != nil {
+			value = s.redact(value)
+		}
+
+		switch s.value.(type) {
+		case *string:
+			value = strconv.Quote(value)
+		case *[]string:
+			value = "[" + strings.Join(quoteAll(*s.value.(*[]string)), ", ") + "]"
+		}
+
+		if _, err := fmt.Fprintf(w, "  %s: %s\n", key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quoteAll(items []string) []string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return quoted
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// redactURL hides the password of a database URL.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
@@ -3,11 +3,11 @@ package database
 import (
 	"context"
 	"database/sql"
-	"time"
 )
 
 type AttendeeModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	opts *QueryOptions
 }
 
 type Attendee struct {
@@ -17,12 +17,12 @@ type Attendee struct {
 }
 
 
-func (m *AttendeeModel) Insert(attendee *Attendee) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+func (m AttendeeModel) Insert(ctx context.Context, attendee *Attendee) (_ *Attendee, err error) {
+	ctx, done := m.opts.begin(ctx, "AttendeeModel.Insert")
+	defer done(&err)
 
 	query := `INSERT INTO attendees (event_id, user_id) VALUES ($1, $2) RETURNING id`
-	err := m.DB.QueryRowContext(ctx, query, attendee.EventId, attendee.UserId).Scan(&attendee.Id)
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, attendee.EventId, attendee.UserId).Scan(&attendee.Id)
 
 	if err != nil {
 		return nil, err
@@ -31,13 +31,13 @@ func (m *AttendeeModel) Insert(attendee *Attendee) (*Attendee, error) {
 	return attendee, nil
 }
 
-func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId int) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+func (m AttendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userId int) (_ *Attendee, err error) {
+	ctx, done := m.opts.begin(ctx, "AttendeeModel.GetByEventAndAttendee")
+	defer done(&err)
 
-	query := `SELECT * FROM attendees WHERE event_id = $1 AND user_id = $2`
+	query := `SELECT id, user_id, event_id FROM attendees WHERE event_id = $1 AND user_id = $2`
 	var attendee Attendee
-	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &attendee.UserId, &attendee.EventId)
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &attendee.UserId, &attendee.EventId)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -47,9 +47,9 @@ func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId int) (*Attendee, e
 	return &attendee, nil
 }
 
-func (m AttendeeModel) GetAttendeesByEvent(eventId int) ([]User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+func (m AttendeeModel) GetAttendeesByEvent(ctx context.Context, eventId int) (_ []User, err error) {
+	ctx, done := m.opts.begin(ctx, "AttendeeModel.GetAttendeesByEvent")
+	defer done(&err)
 
 	query := `
      SELECT u.id, u.name, u.email
@@ -57,7 +57,7 @@ func (m AttendeeModel) GetAttendeesByEvent(eventId int) ([]User, error) {
      JOIN attendees a ON u.id = a.user_id
      WHERE a.event_id = $1
  `
-	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, eventId)
 	if err != nil {
 		return nil, err
 	}
@@ -75,14 +75,153 @@ func (m AttendeeModel) GetAttendeesByEvent(eventId int) ([]User, error) {
 	return users, nil
 }
 
-func (m *AttendeeModel) Delete(userId, eventId int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+func (m AttendeeModel) Delete(ctx context.Context, userId, eventId int) (err error) {
+	ctx, done := m.opts.begin(ctx, "AttendeeModel.Delete")
+	defer done(&err)
 
 	query := `DELETE FROM attendees WHERE user_id = $1 AND event_id = $2`
-	_, err := m.DB.ExecContext(ctx, query, userId, eventId)
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, userId, eventId)
 	if err != nil {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+const (
+	AttendeeActionAdd    = "add"
+	AttendeeActionRemove = "remove"
+)
+
+const (
+	AttendeeStatusAdded            = "added"
+	AttendeeStatusRemoved          = "removed"
+	AttendeeStatusUserNotFound     = "user_not_found"
+	AttendeeStatusAlreadyAttending = "already_attending"
+	AttendeeStatusNotAttending     = "not_attending"
+	AttendeeStatusRolledBack       = "rolled_back"
+)
+
+// AttendeeChange adds or removes one user, identified by ID or by email.
+type AttendeeChange struct {
+	Action string `json:"action"`
+	UserId int    `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+type AttendeeChangeResult struct {
+	AttendeeChange
+	Status string `json:"status"`
+}
+
+// Succeeded reports whether the change was applied or would have been applied.
+func (r AttendeeChangeResult) Succeeded() bool {
+	return r.Status == AttendeeStatusAdded || r.Status == AttendeeStatusRemoved
+}
+
+// ApplyChanges applies a list of attendee changes to an event in a single transaction and
+// returns the outcome of each one. In atomic mode a single failed change rolls back the
+// whole batch and the changes that would have succeeded are reported as rolled back;
+// otherwise every change that can be applied is committed. It reports whether the
+// changes were applied.
+func (m AttendeeModel) ApplyChanges(ctx context.Context, eventId int, changes []AttendeeChange, atomic bool) (_ []AttendeeChangeResult, _ bool, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "AttendeeModel.ApplyChanges")
+	defer done(&err)
+
+	var results []AttendeeChangeResult
+	applied := false
+
+	err = inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		// The savepoint lets an atomic batch undo its own changes without aborting a
+		// transaction the caller may have started.
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT apply_attendee_changes"); err != nil {
+			return err
+		}
+
+		results = make([]AttendeeChangeResult, 0, len(changes))
+		failed := false
+
+		for _, change := range changes {
+			result := AttendeeChangeResult{AttendeeChange: change}
+
+			status, err := applyAttendeeChange(ctx, tx, eventId, &result.AttendeeChange)
+			if err != nil {
+				return err
+			}
+			result.Status = status
+
+			if !result.Succeeded() {
+				failed = true
+			}
+			results = append(results, result)
+		}
+
+		if atomic && failed {
+			for i := range results {
+				if results[i].Succeeded() {
+					results[i].Status = AttendeeStatusRolledBack
+				}
+			}
+			_, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT apply_attendee_changes")
+			return err
+		}
+
+		applied = true
+		_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT apply_attendee_changes")
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results, applied, nil
+}
+
+// applyAttendeeChange resolves the user of a change to its ID and applies it within tx. The
+// email is left as the caller sent it, so it is never disclosed for a user added by ID.
+func applyAttendeeChange(ctx context.Context, tx *sql.Tx, eventId int, change *AttendeeChange) (string, error) {
+	lookup, arg := `SELECT id FROM users WHERE id = $1`, any(change.UserId)
+	if change.UserId == 0 {
+		lookup, arg = `SELECT id FROM users WHERE email = $1`, change.Email
+	}
+
+	err := tx.QueryRowContext(ctx, lookup, arg).Scan(&change.UserId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AttendeeStatusUserNotFound, nil
+		}
+		return "", err
+	}
+
+	if change.Action == AttendeeActionRemove {
+		result, err := tx.ExecContext(ctx, `DELETE FROM attendees WHERE user_id = $1 AND event_id = $2`, change.UserId, eventId)
+		if err != nil {
+			return "", err
+		}
+
+		removed, err := result.RowsAffected()
+		if err != nil {
+			return "", err
+		}
+
+		if removed == 0 {
+			return AttendeeStatusNotAttending, nil
+		}
+		return AttendeeStatusRemoved, nil
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM attendees WHERE event_id = $1 AND user_id = $2)`, eventId, change.UserId).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+
+	if exists {
+		return AttendeeStatusAlreadyAttending, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO attendees (event_id, user_id) VALUES ($1, $2)`, eventId, change.UserId)
+	if err != nil {
+		return "", err
+	}
+	return AttendeeStatusAdded, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrBackupUnsupported is returned by Backup for databases other than SQLite; use the
+// database's own tooling, such as pg_dump, to back those up.
+var ErrBackupUnsupported = errors.New("online backup is only supported for SQLite")
+
+// Backup copies a live SQLite database to a new file at dest using the SQLite online backup
+// API. The copy is a consistent snapshot, and in WAL mode writers are not blocked while it is
+// taken. dest must not exist yet.
+func Backup(ctx context.Context, db *sql.DB, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination %s already exists", dest)
+	}
+
+	source, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return source.Raw(func(sourceConn any) error {
+		src, ok := sourceConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return ErrBackupUnsupported
+		}
+
+		if err := copySQLite(ctx, src, dest); err != nil {
+			os.Remove(dest)
+			return err
+		}
+		return nil
+	})
+}
+
+func copySQLite(ctx context.Context, src *sqlite3.SQLiteConn, dest string) error {
+	target, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	destination, err := target.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	return destination.Raw(func(destConn any) error {
+		backup, err := destConn.(*sqlite3.SQLiteConn).Backup("main", src, "main")
+		if err != nil {
+			return err
+		}
+
+		// Copying every page in one step gives a consistent snapshot; copying in smaller
+		// steps would restart whenever another connection writes in between.
+		if _, err := backup.Step(-1); err != nil {
+			backup.Close()
+			return err
+		}
+		return backup.Finish()
+	})
+}
+
+// BackupPath returns a timestamped path for a new backup in dir.
+func BackupPath(dir string, now time.Time) string {
+	return filepath.Join(dir, "data-"+now.UTC().Format("20060102T150405Z")+".db")
+}
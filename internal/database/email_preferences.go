@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+)
+
+type EmailPreferenceModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// Categories of email a user can turn off. Emails outside them, such as password resets,
+// are always sent.
+const (
+	EmailCategoryInvitations   = "invitations"
+	EmailCategoryReminders     = "reminders"
+	EmailCategoryCancellations = "cancellations"
+)
+
+// EmailPreferences are the email settings of a user. UnsubscribeToken identifies the user
+// in unsubscribe links, which work without signing in.
+type EmailPreferences struct {
+	UserId           int    `json:"-"`
+	Locale           string `json:"locale"`
+	Invitations      bool   `json:"invitations"`
+	Reminders        bool   `json:"reminders"`
+	Cancellations    bool   `json:"cancellations"`
+	UnsubscribeToken string `json:"-"`
+}
+
+// Allows reports whether the user wants emails of a category.
+func (p *EmailPreferences) Allows(category string) bool {
+	switch category {
+	case EmailCategoryInvitations:
+		return p.Invitations
+	case EmailCategoryReminders:
+		return p.Reminders
+	case EmailCategoryCancellations:
+		return p.Cancellations
+	}
+	return true
+}
+
+// Unsubscribe turns off a category, or every category if category is empty. It reports
+// false for an unknown category.
+func (p *EmailPreferences) Unsubscribe(category string) bool {
+	switch category {
+	case EmailCategoryInvitations:
+		p.Invitations = false
+	case EmailCategoryReminders:
+		p.Reminders = false
+	case EmailCategoryCancellations:
+		p.Cancellations = false
+	case "":
+		p.Invitations, p.Reminders, p.Cancellations = false, false, false
+	default:
+		return false
+	}
+	return true
+}
+
+// Get returns the preferences of a user, creating the defaults, with every category on,
+// if the user has none yet.
+func (m EmailPreferenceModel) Get(ctx context.Context, userId int) (_ *EmailPreferences, err error) {
+	ctx, done := m.opts.begin(ctx, "EmailPreferenceModel.Get")
+	defer done(&err)
+
+	insert := `
+		INSERT INTO email_preferences (user_id, unsubscribe_token)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	if _, err := conn(ctx, m.DB).ExecContext(ctx, insert, userId, newUnsubscribeToken()); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT user_id, locale, invitations, reminders, cancellations, unsubscribe_token
+		FROM email_preferences
+		WHERE user_id = $1
+	`
+
+	return scanEmailPreferences(conn(ctx, m.DB).QueryRowContext(ctx, query, userId))
+}
+
+// GetByToken returns the preferences an unsubscribe token belongs to, or nil if there
+// are none.
+func (m EmailPreferenceModel) GetByToken(ctx context.Context, token string) (_ *EmailPreferences, err error) {
+	ctx, done := m.opts.begin(ctx, "EmailPreferenceModel.GetByToken")
+	defer done(&err)
+
+	query := `
+		SELECT user_id, locale, invitations, reminders, cancellations, unsubscribe_token
+		FROM email_preferences
+		WHERE unsubscribe_token = $1
+	`
+
+	prefs, err := scanEmailPreferences(conn(ctx, m.DB).QueryRowContext(ctx, query, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prefs, err
+}
+
+func scanEmailPreferences(row scanner) (*EmailPreferences, error) {
+	var p EmailPreferences
+	err := row.Scan(&p.UserId, &p.Locale, &p.Invitations, &p.Reminders, &p.Cancellations, &p.UnsubscribeToken)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Update saves a user's locale and categories.
+func (m EmailPreferenceModel) Update(ctx context.Context, prefs *EmailPreferences) (err error) {
+	ctx, done := m.opts.begin(ctx, "EmailPreferenceModel.Update")
+	defer done(&err)
+
+	query := `
+		UPDATE email_preferences
+		SET locale = $1, invitations = $2, reminders = $3, cancellations = $4
+		WHERE user_id = $5
+	`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, prefs.Locale, prefs.Invitations, prefs.Reminders, prefs.Cancellations, prefs.UserId)
+	return err
+}
+
+func newUnsubscribeToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type EmailModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+)
+
+// Email is a rendered email in the outbox. It is sent by a job, which retries it when
+// sending fails.
+type Email struct {
+	Id             int
+	UserId         int
+	Recipient      string
+	Template       string
+	Subject        string
+	TextBody       string
+	HTMLBody       string
+	UnsubscribeURL string
+	Status         string
+	CreatedAt      time.Time
+}
+
+// Enqueue adds a pending email to the outbox.
+func (m EmailModel) Enqueue(ctx context.Context, email *Email) (err error) {
+	ctx, done := m.opts.begin(ctx, "EmailModel.Enqueue")
+	defer done(&err)
+
+	query := `
+		INSERT INTO email_outbox (user_id, recipient, template, subject, text_body, html_body, unsubscribe_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`
+
+	return conn(ctx, m.DB).QueryRowContext(ctx, query, email.UserId, email.Recipient, email.Template, email.Subject,
+		email.TextBody, email.HTMLBody, email.UnsubscribeURL).
+		Scan(&email.Id, &email.Status, &email.CreatedAt)
+}
+
+// GetById returns an email from the outbox, or nil if there is none with that ID, as when
+// its user has been deleted.
+func (m EmailModel) GetById(ctx context.Context, id int) (_ *Email, err error) {
+	ctx, done := m.opts.begin(ctx, "EmailModel.GetById")
+	defer done(&err)
+
+	query := `
+		SELECT id, user_id, recipient, template, subject, text_body, html_body, unsubscribe_url, status, created_at
+		FROM email_outbox
+		WHERE id = $1
+	`
+
+	var email Email
+	var userId sql.NullInt64
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, id).Scan(&email.Id, &userId, &email.Recipient, &email.Template,
+		&email.Subject, &email.TextBody, &email.HTMLBody, &email.UnsubscribeURL, &email.Status, &email.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	email.UserId = int(userId.Int64)
+	return &email, nil
+}
+
+// MarkSent records that an email was delivered.
+func (m EmailModel) MarkSent(ctx context.Context, id int) (err error) {
+	ctx, done := m.opts.begin(ctx, "EmailModel.MarkSent")
+	defer done(&err)
+
+	query := "UPDATE email_outbox SET status = $1, sent_at = $2 WHERE id = $3"
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, EmailStatusSent, time.Now().UTC(), id)
+	return err
+}
@@ -3,30 +3,124 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
 type EventModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	opts *QueryOptions
 }
 
+// ErrEditConflict is returned when an event was changed by someone else since it was read.
+var ErrEditConflict = errors.New("edit conflict")
+
+// ErrRecordNotFound is returned when a write finds no row to change.
+var ErrRecordNotFound = errors.New("record not found")
+
 type Event struct {
-	Id          int    `json:"id"`
-	OwnerId     int    `json:"ownerId" binding:"required"`
-	Name        string `json:"name" binding:"required,min=3"`
-	Description string `json:"description" binding:"required,min=10"`
-	Date        string `json:"date" binding:"required,datetime=2006-01-02"`
-	Location    string `json:"location" binding:"required,min=3"`
+	Id              int        `json:"id"`
+	OwnerId         int        `json:"ownerId" binding:"required"`
+	Name            string     `json:"name" binding:"required,min=3"`
+	Description     string     `json:"description" binding:"required,min=10"`
+	Date            string     `json:"date" binding:"required,datetime=2006-01-02"`
+	Location        string     `json:"location" binding:"required,min=3"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"statusReason,omitempty"`
+	StatusChangedAt *time.Time `json:"statusChangedAt,omitempty"`
+	PublishAt       *time.Time `json:"publishAt,omitempty"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
+	Version         int        `json:"version"`
+}
+
+const (
+	EventStatusDraft     = "draft"
+	EventStatusPublished = "published"
+	EventStatusCancelled = "cancelled"
+	EventStatusPostponed = "postponed"
+	EventStatusCompleted = "completed"
+)
+
+// eventTransitions lists the statuses an event may move to from each status.
+// Cancelled and completed events are final.
+var eventTransitions = map[string][]string{
+	EventStatusDraft:     {EventStatusPublished, EventStatusCancelled},
+	EventStatusPublished: {EventStatusCancelled, EventStatusPostponed, EventStatusCompleted},
+	EventStatusPostponed: {EventStatusPublished, EventStatusCancelled},
+	EventStatusCancelled: {},
+	EventStatusCompleted: {},
+}
+
+// AllowedTransitions returns the statuses the event can currently move to.
+func (e *Event) AllowedTransitions() []string {
+	return eventTransitions[e.Status]
+}
+
+// VisibleTo reports whether a user may see the event. Drafts are only visible to their owner.
+func (e *Event) VisibleTo(userId int) bool {
+	return e.Status != EventStatusDraft || e.OwnerId == userId
+}
+
+func (e *Event) CanTransitionTo(status string) bool {
+	for _, allowed := range eventTransitions[e.Status] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
 }
 
-func (m EventModel) Insert(event *Event) error {
-	// context is used to control the lifetime of the query and also set timeouts.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// eventColumns lists the columns read by scanEvent, in scan order.
+const eventColumns = "e.id, e.owner_id, e.name, e.description, e.date, e.location, e.status, e.status_reason, e.status_changed_at, e.publish_at, e.deleted_at, e.version"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
 
-	query := "INSERT INTO events (owner_id, name, description, date, location) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+func scanEvent(row scanner) (*Event, error) {
+	var event Event
+	var statusChangedAt, publishAt, deletedAt sql.NullTime
 
-	err := m.DB.QueryRowContext(ctx, query, event.OwnerId, event.Name, event.Description, event.Date, event.Location).Scan(&event.Id)
+	err := row.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location,
+		&event.Status, &event.StatusReason, &statusChangedAt, &publishAt, &deletedAt, &event.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusChangedAt.Valid {
+		event.StatusChangedAt = &statusChangedAt.Time
+	}
+
+	if publishAt.Valid {
+		event.PublishAt = &publishAt.Time
+	}
+
+	if deletedAt.Valid {
+		event.DeletedAt = &deletedAt.Time
+	}
+
+	return &event, nil
+}
+
+func (m EventModel) Insert(ctx context.Context, event *Event) (err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Insert")
+	defer done(&err)
+
+	if event.Status == "" {
+		event.Status = EventStatusPublished
+	}
+
+	var publishAt any
+	if event.PublishAt != nil {
+		publishAt = event.PublishAt.UTC()
+	}
+
+	query := "INSERT INTO events (owner_id, name, description, date, location, status, publish_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, version"
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, event.OwnerId, event.Name, event.Description, event.Date, event.Location, event.Status, publishAt).Scan(&event.Id, &event.Version)
 	if err != nil {
 		return err
 	}
@@ -34,13 +128,15 @@ func (m EventModel) Insert(event *Event) error {
 	return nil
 }
 
-func (m EventModel) GetAll() ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// GetAll returns every event visible to the user: all non-draft events plus the user's own drafts.
+// Anonymous callers pass 0 and only see non-draft events.
+func (m EventModel) GetAll(ctx context.Context, viewerId int) (_ []*Event, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.GetAll")
+	defer done(&err)
 
-	query := "SELECT * FROM events"
+	query := "SELECT " + eventColumns + " FROM events e WHERE e.deleted_at IS NULL AND (e.status != $1 OR e.owner_id = $2)"
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, EventStatusDraft, viewerId)
 	if err != nil {
 		return nil, err
 	}
@@ -49,12 +145,11 @@ func (m EventModel) GetAll() ([]*Event, error) {
 	events := []*Event{}
 
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -64,17 +159,13 @@ func (m EventModel) GetAll() ([]*Event, error) {
 	return events, nil
 }
 
-func (m EventModel) GetById(id int) (*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := "SELECT * FROM events WHERE id = $1"
-
-	row := m.DB.QueryRowContext(ctx, query, id)
+func (m EventModel) GetById(ctx context.Context, id int) (_ *Event, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.GetById")
+	defer done(&err)
 
-	var event Event
+	query := "SELECT " + eventColumns + " FROM events e WHERE e.id = $1 AND e.deleted_at IS NULL"
 
-	err := row.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+	event, err := scanEvent(conn(ctx, m.DB).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -82,46 +173,244 @@ func (m EventModel) GetById(id int) (*Event, error) {
 		return nil, err
 	}
 
-	return &event, nil
+	return event, nil
 }
 
-func (m EventModel) Update(event *Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// Update writes the event if it is still at event.Version, and bumps the version.
+// It returns ErrEditConflict if the event was changed in the meantime.
+func (m EventModel) Update(ctx context.Context, event *Event) (err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Update")
+	defer done(&err)
 
-	query := "UPDATE events SET name = $1, description = $2, date = $3, location = $4 WHERE id = $5"
+	query := `
+		UPDATE events SET name = $1, description = $2, date = $3, location = $4, version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version
+	`
 
-	_, err := m.DB.ExecContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.Id)
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.Id, event.Version).Scan(&event.Version)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrEditConflict
+		}
 		return err
 	}
 	return nil
 }
 
-func (m EventModel) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// updatableEventColumns are the columns UpdateFields is allowed to write.
+var updatableEventColumns = map[string]bool{
+	"name":        true,
+	"description": true,
+	"date":        true,
+	"location":    true,
+}
+
+// UpdateFields writes only the given columns of an event, with the same version check as Update.
+// Keys must be column names from updatableEventColumns; anything else is rejected so callers
+// cannot build arbitrary SQL.
+func (m EventModel) UpdateFields(ctx context.Context, event *Event, changes map[string]any) (err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.UpdateFields")
+	defer done(&err)
 
-	query := "DELETE FROM events WHERE id = $1"
+	if len(changes) == 0 {
+		return nil
+	}
+
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		if !updatableEventColumns[column] {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	assignments := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns)+1)
+	for i, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, i+1))
+		args = append(args, changes[column])
+	}
+	args = append(args, event.Id, event.Version)
 
-	_, err := m.DB.ExecContext(ctx, query, id)
+	query := fmt.Sprintf(
+		"UPDATE events SET %s, version = version + 1 WHERE id = $%d AND version = $%d AND deleted_at IS NULL RETURNING version",
+		strings.Join(assignments, ", "), len(args)-1, len(args),
+	)
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, args...).Scan(&event.Version)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrEditConflict
+		}
 		return err
 	}
 	return nil
 }
 
-func (m EventModel) GetByAttendee(attendeeId int) ([]Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// Transition moves an event from its current status to a new one, optionally moving it to a
+// new date. It reports false if the event is no longer in the status it was read with.
+func (m EventModel) Transition(ctx context.Context, event *Event, status, reason, date string) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Transition")
+	defer done(&err)
+
+	if date == "" {
+		date = event.Date
+	}
+
+	changedAt := time.Now().UTC()
 
+	// Publishing clears any pending schedule so the scheduler does not act on it later.
 	query := `
-		SELECT e.id, e.owner_id, e.name, e.description, e.date, e.location
+		UPDATE events SET status = $1, status_reason = $2, status_changed_at = $3, date = $4,
+			publish_at = CASE WHEN CAST($1 AS TEXT) = 'published' THEN NULL ELSE publish_at END, version = version + 1
+		WHERE id = $5 AND status = $6 AND deleted_at IS NULL
+		RETURNING version
+	`
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, status, reason, changedAt, date, event.Id, event.Status).Scan(&event.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	event.Status = status
+	event.StatusReason = reason
+	event.StatusChangedAt = &changedAt
+	event.Date = date
+	if status == EventStatusPublished {
+		event.PublishAt = nil
+	}
+
+	return true, nil
+}
+
+// Schedule sets or clears the time at which a draft is published automatically.
+// It reports false if the event is no longer a draft.
+func (m EventModel) Schedule(ctx context.Context, event *Event, publishAt *time.Time) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Schedule")
+	defer done(&err)
+
+	var value any
+	if publishAt != nil {
+		value = publishAt.UTC()
+	}
+
+	query := `
+		UPDATE events SET publish_at = $1, version = version + 1
+		WHERE id = $2 AND status = $3 AND deleted_at IS NULL
+		RETURNING version
+	`
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, value, event.Id, EventStatusDraft).Scan(&event.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	event.PublishAt = publishAt
+	return true, nil
+}
+
+// PublishDue publishes every scheduled draft whose publish time has passed and returns their IDs.
+// Because the schedule is stored on the row, drafts that came due while the server was down are
+// published on the next run.
+func (m EventModel) PublishDue(ctx context.Context, now time.Time) (_ []int, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "EventModel.PublishDue")
+	defer done(&err)
+
+	query := `
+		UPDATE events SET status = $1, status_changed_at = $2, publish_at = NULL, version = version + 1
+		WHERE status = $3 AND publish_at IS NOT NULL AND publish_at <= $2 AND deleted_at IS NULL
+		RETURNING id
+	`
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, EventStatusPublished, now.UTC(), EventStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// NextPublishAt returns the earliest pending publish time, or nil if nothing is scheduled.
+func (m EventModel) NextPublishAt(ctx context.Context) (_ *time.Time, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.NextPublishAt")
+	defer done(&err)
+
+	query := `
+		SELECT publish_at FROM events
+		WHERE status = $1 AND publish_at IS NOT NULL AND deleted_at IS NULL
+		ORDER BY publish_at
+		LIMIT 1
+	`
+
+	var publishAt sql.NullTime
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, EventStatusDraft).Scan(&publishAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &publishAt.Time, nil
+}
+
+// Delete moves an event to the trash if it is still at the given version. The row and its
+// attendees are kept until Purge removes it, so the event can still be brought back with Restore.
+func (m EventModel) Delete(ctx context.Context, id, version int) (err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Delete")
+	defer done(&err)
+
+	query := "UPDATE events SET deleted_at = $1, version = version + 1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, time.Now().UTC(), id, version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (m EventModel) GetByAttendee(ctx context.Context, attendeeId int) (_ []Event, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.GetByAttendee")
+	defer done(&err)
+
+	query := `
+		SELECT ` + eventColumns + `
 		FROM events e
 		JOIN attendees a ON e.id = a.event_id
-		WHERE a.user_id = $1
+		WHERE a.user_id = $1 AND e.deleted_at IS NULL AND e.status != $2
 	`
-	rows, err := m.DB.QueryContext(ctx, query, attendeeId)
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, attendeeId, EventStatusDraft)
 	if err != nil {
 		return nil, err
 	}
@@ -129,12 +418,102 @@ func (m EventModel) GetByAttendee(attendeeId int) ([]Event, error) {
 
 	var events []Event
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.Id, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	return events, nil
+}
+
+// GetDeletedById returns an event only if it is in the trash.
+func (m EventModel) GetDeletedById(ctx context.Context, id int) (_ *Event, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.GetDeletedById")
+	defer done(&err)
+
+	query := "SELECT " + eventColumns + " FROM events e WHERE e.id = $1 AND e.deleted_at IS NOT NULL"
+
+	event, err := scanEvent(conn(ctx, m.DB).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetDeletedByOwner lists the trashed events of an owner, most recently deleted first.
+func (m EventModel) GetDeletedByOwner(ctx context.Context, ownerId int) (_ []*Event, err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.GetDeletedByOwner")
+	defer done(&err)
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events e
+		WHERE e.owner_id = $1 AND e.deleted_at IS NOT NULL
+		ORDER BY e.deleted_at DESC
+	`
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, event)
 	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return events, nil
 }
+
+// Restore brings an event back out of the trash. It returns ErrRecordNotFound if the
+// event is not in the trash.
+func (m EventModel) Restore(ctx context.Context, id int) (err error) {
+	ctx, done := m.opts.begin(ctx, "EventModel.Restore")
+	defer done(&err)
+
+	query := "UPDATE events SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Purge permanently removes events that were deleted before the cutoff, returning how many were removed.
+// Their attendees, revisions and notifications go with them through ON DELETE CASCADE.
+func (m EventModel) Purge(ctx context.Context, cutoff time.Time) (_ int64, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "EventModel.Purge")
+	defer done(&err)
+
+	query := "DELETE FROM events WHERE deleted_at IS NOT NULL AND deleted_at <= $1"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type IdempotencyKeyModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// IdempotencyKey records a request made with an Idempotency-Key header. StatusCode is nil
+// while the original request is still being processed. ResponseHeaders are the headers the
+// original response set besides its Content-Type, such as ETag and Location.
+type IdempotencyKey struct {
+	UserId          int
+	Key             string
+	Fingerprint     string
+	StatusCode      *int
+	ContentType     string
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+	ExpiresAt       time.Time
+}
+
+// Get returns an unexpired key, or nil if the user has not used it.
+func (m IdempotencyKeyModel) Get(ctx context.Context, userId int, key string) (_ *IdempotencyKey, err error) {
+	ctx, done := m.opts.begin(ctx, "IdempotencyKeyModel.Get")
+	defer done(&err)
+
+	query := `
+		SELECT user_id, key, fingerprint, status_code, content_type, response_headers, response_body, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expires_at > $3
+	`
+
+	var k IdempotencyKey
+	var statusCode sql.NullInt64
+	var headers string
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, userId, key, time.Now().UTC()).
+		Scan(&k.UserId, &k.Key, &k.Fingerprint, &statusCode, &k.ContentType, &headers, &k.ResponseBody, &k.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		k.StatusCode = &code
+	}
+
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &k.ResponseHeaders); err != nil {
+			return nil, err
+		}
+	}
+
+	return &k, nil
+}
+
+// Insert claims a key for a new request. It reports false if another request already holds
+// the key; an expired key is replaced.
+func (m IdempotencyKeyModel) Insert(ctx context.Context, k *IdempotencyKey) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "IdempotencyKeyModel.Insert")
+	defer done(&err)
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND expires_at <= $3",
+		k.UserId, k.Key, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO NOTHING
+	`
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, k.UserId, k.Key, k.Fingerprint, k.ExpiresAt.UTC())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// Complete stores the response of the original request so retries can replay it.
+func (m IdempotencyKeyModel) Complete(ctx context.Context, k *IdempotencyKey) (err error) {
+	ctx, done := m.opts.begin(ctx, "IdempotencyKeyModel.Complete")
+	defer done(&err)
+
+	headers := ""
+	if len(k.ResponseHeaders) > 0 {
+		data, err := json.Marshal(k.ResponseHeaders)
+		if err != nil {
+			return err
+		}
+		headers = string(data)
+	}
+
+	query := `
+		UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_headers = $3, response_body = $4
+		WHERE user_id = $5 AND key = $6
+	`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, k.StatusCode, k.ContentType, headers, k.ResponseBody, k.UserId, k.Key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete releases a key so the request can be retried, for example after a server error.
+func (m IdempotencyKeyModel) Delete(ctx context.Context, userId int, key string) (err error) {
+	ctx, done := m.opts.begin(ctx, "IdempotencyKeyModel.Delete")
+	defer done(&err)
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, userId, key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes keys whose TTL has passed, returning how many were removed.
+func (m IdempotencyKeyModel) DeleteExpired(ctx context.Context, now time.Time) (_ int64, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "IdempotencyKeyModel.DeleteExpired")
+	defer done(&err)
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
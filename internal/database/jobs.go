@@ -0,0 +1,338 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type JobModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// A job is pending until a runner claims it, running while the runner holds its lease, and
+// then succeeded, pending again to be retried, or failed once it has no attempts left.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a unit of background work. Payload is the JSON the job's handler is given.
+// RunAt is when a pending job is next due; LockedBy and LockedUntil name the runner
+// holding a running job and the end of its lease.
+type Job struct {
+	Id          int             `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   string          `json:"lastError"`
+	RunAt       time.Time       `json:"runAt"`
+	LockedBy    string          `json:"lockedBy,omitempty"`
+	LockedUntil *time.Time      `json:"lockedUntil"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	FinishedAt  *time.Time      `json:"finishedAt"`
+}
+
+// JobFilter selects a page of jobs, newest first. Before, if set, is the ID of the last
+// job of the previous page.
+type JobFilter struct {
+	Status string
+	Type   string
+	Before int
+	Limit  int
+}
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, last_error, run_at, locked_by, locked_until, created_at, finished_at`
+
+func scanJob(row scanner) (*Job, error) {
+	var job Job
+	var payload string
+	var lockedUntil, finishedAt sql.NullTime
+
+	err := row.Scan(&job.Id, &job.Type, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.LastError,
+		&job.RunAt, &job.LockedBy, &lockedUntil, &job.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = json.RawMessage(payload)
+
+	if lockedUntil.Valid {
+		job.LockedUntil = &lockedUntil.Time
+	}
+
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return &job, nil
+}
+
+func scanJobs(rows *sql.Rows) ([]*Job, error) {
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Enqueue adds a pending job, due at its RunAt.
+func (m JobModel) Enqueue(ctx context.Context, job *Job) (err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Enqueue")
+	defer done(&err)
+
+	query := `
+		INSERT INTO jobs (type, payload, max_attempts, run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + jobColumns
+
+	created, err := scanJob(conn(ctx, m.DB).QueryRowContext(ctx, query, job.Type, string(job.Payload), job.MaxAttempts,
+		job.RunAt.UTC(), time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+
+	*job = *created
+	return nil
+}
+
+// Claim leases up to limit due jobs of the given types to runner until now+lease, counting
+// an attempt for each. Due jobs are pending jobs whose time has come and running jobs whose
+// lease has run out because their runner died, so every job runs at least once even when
+// several instances share the table.
+func (m JobModel) Claim(ctx context.Context, runner string, types []string, now time.Time, lease time.Duration, limit int) (_ []*Job, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Claim")
+	defer done(&err)
+
+	if len(types) == 0 || limit <= 0 {
+		return []*Job{}, nil
+	}
+
+	// SQLite numbers parameters in the order they first appear, so the limit comes last.
+	args := []any{JobStatusRunning, runner, now.Add(lease).UTC(), JobStatusPending, now.UTC()}
+	placeholders := make([]string, len(types))
+	for i, t := range types {
+		args = append(args, t)
+		placeholders[i] = "$" + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	limitParam := "$" + strconv.Itoa(len(args))
+
+	// The due condition is checked again against rows a concurrent claim has just updated,
+	// so two runners never lease the same job.
+	due := `((status = $4 AND run_at <= $5) OR (status = $1 AND locked_until <= $5)) AND type IN (` + strings.Join(placeholders, ", ") + `)`
+	query := `
+		UPDATE jobs
+		SET status = $1, locked_by = $2, locked_until = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE ` + due + `
+			ORDER BY run_at
+			LIMIT ` + limitParam + `
+		)
+		AND ` + due + `
+		RETURNING ` + jobColumns
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// Extend renews runner's lease on a job until now+lease, and reports false if the runner
+// no longer holds it.
+func (m JobModel) Extend(ctx context.Context, id int, runner string, now time.Time, lease time.Duration) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Extend")
+	defer done(&err)
+
+	query := "UPDATE jobs SET locked_until = $1 WHERE id = $2 AND status = $3 AND locked_by = $4"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, now.Add(lease).UTC(), id, JobStatusRunning, runner)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Complete records that runner finished a job. It does nothing if the runner lost its
+// lease, since the job has been handed to another runner.
+func (m JobModel) Complete(ctx context.Context, id int, runner string) (err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Complete")
+	defer done(&err)
+
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = '', locked_by = '', locked_until = NULL, finished_at = $2
+		WHERE id = $3 AND status = $4 AND locked_by = $5
+	`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, JobStatusSucceeded, time.Now().UTC(), id, JobStatusRunning, runner)
+	return err
+}
+
+// Fail records that a job run by runner failed. The job runs again at retryAt, or is
+// given up on if retryAt is nil. Like Complete, it does nothing if the runner lost its
+// lease.
+func (m JobModel) Fail(ctx context.Context, id int, runner, reason string, retryAt *time.Time) (err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Fail")
+	defer done(&err)
+
+	now := time.Now().UTC()
+	status, runAt, finishedAt := JobStatusFailed, now, &now
+	if retryAt != nil {
+		status, runAt, finishedAt = JobStatusPending, retryAt.UTC(), nil
+	}
+
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = $2, run_at = $3, finished_at = $4, locked_by = '', locked_until = NULL
+		WHERE id = $5 AND status = $6 AND locked_by = $7
+	`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, status, reason, runAt, finishedAt, id, JobStatusRunning, runner)
+	return err
+}
+
+// NextRunAt returns when the next pending job of the given types is due, or nil if there
+// is none. Running jobs whose lease runs out are not counted.
+func (m JobModel) NextRunAt(ctx context.Context, types []string) (_ *time.Time, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.NextRunAt")
+	defer done(&err)
+
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	args := []any{JobStatusPending}
+	placeholders := make([]string, len(types))
+	for i, t := range types {
+		args = append(args, t)
+		placeholders[i] = "$" + strconv.Itoa(len(args))
+	}
+
+	query := `
+		SELECT run_at FROM jobs
+		WHERE status = $1 AND type IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY run_at
+		LIMIT 1
+	`
+
+	var next time.Time
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, args...).Scan(&next)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// GetById returns a job, or nil if there is no such job.
+func (m JobModel) GetById(ctx context.Context, id int) (_ *Job, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.GetById")
+	defer done(&err)
+
+	query := "SELECT " + jobColumns + " FROM jobs WHERE id = $1"
+
+	job, err := scanJob(conn(ctx, m.DB).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// List returns a page of jobs, newest first.
+func (m JobModel) List(ctx context.Context, filter JobFilter) (_ []*Job, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.List")
+	defer done(&err)
+
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if filter.Status != "" {
+		add("status =", filter.Status)
+	}
+	if filter.Type != "" {
+		add("type =", filter.Type)
+	}
+	if filter.Before > 0 {
+		add("id <", filter.Before)
+	}
+
+	query := "SELECT " + jobColumns + " FROM jobs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// Retry makes a failed job pending again, due now and with its attempts reset, and
+// returns it, or nil if there is no failed job with that ID.
+func (m JobModel) Retry(ctx context.Context, id int) (_ *Job, err error) {
+	ctx, done := m.opts.begin(ctx, "JobModel.Retry")
+	defer done(&err)
+
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = 0, run_at = $2, finished_at = NULL
+		WHERE id = $3 AND status = $4
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(conn(ctx, m.DB).QueryRowContext(ctx, query, JobStatusPending, time.Now().UTC(), id, JobStatusFailed))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Purge deletes the jobs that succeeded before cutoff and returns how many it deleted.
+// Failed jobs are kept until they are retried.
+func (m JobModel) Purge(ctx context.Context, cutoff time.Time) (_ int64, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "JobModel.Purge")
+	defer done(&err)
+
+	query := "DELETE FROM jobs WHERE status = $1 AND finished_at < $2"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, JobStatusSucceeded, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
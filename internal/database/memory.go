@@ -0,0 +1,668 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errDuplicateEmail = errors.New("a user with this email already exists")
+
+// MemoryStore keeps every model's data in memory, mirroring the behaviour of the SQLite
+// models. It is safe for concurrent use and is meant for tests.
+type MemoryStore struct {
+	mu sync.RWMutex
+	memoryData
+}
+
+// memoryData is the contents of a MemoryStore. Rows that are changed in place are held by
+// pointer and copied by clone; the others are held by value and replaced whole.
+type memoryData struct {
+	users           map[int]*User
+	events          map[int]*Event
+	attendees       []Attendee
+	revisions       []EventRevision
+	notifications   []Notification
+	idempotencyKeys map[idempotencyKeyId]IdempotencyKey
+	emails          []Email
+	emailPrefs      map[int]EmailPreferences
+	passwordResets  map[string]passwordReset
+	remindersOff    map[int]bool // by attendee ID
+	remindersSent   map[reminderId]bool
+	jobs            []Job
+
+	nextUserId         int
+	nextEventId        int
+	nextAttendeeId     int
+	nextRevisionId     int
+	nextNotificationId int
+	nextEmailId        int
+	nextJobId          int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryData: memoryData{
+		users:           make(map[int]*User),
+		events:          make(map[int]*Event),
+		idempotencyKeys: make(map[idempotencyKeyId]IdempotencyKey),
+		emailPrefs:      make(map[int]EmailPreferences),
+		passwordResets:  make(map[string]passwordReset),
+		remindersOff:    make(map[int]bool),
+		remindersSent:   make(map[reminderId]bool),
+	}}
+}
+
+// NewMemoryModels returns Models whose data is kept in a fresh MemoryStore.
+func NewMemoryModels() Models {
+	store := NewMemoryStore()
+	return Models{
+		Users:            store.Users(),
+		Events:           store.Events(),
+		Attendees:        store.Attendees(),
+		EventRevisions:   memoryRevisions{store},
+		Notifications:    memoryNotifications{store},
+		IdempotencyKeys:  memoryIdempotencyKeys{store},
+		Emails:           memoryEmails{store},
+		EmailPreferences: memoryEmailPreferences{store},
+		PasswordResets:   memoryPasswordResets{store},
+		Reminders:        memoryReminders{store},
+		Jobs:             memoryJobs{store},
+		transactor:       store,
+	}
+}
+
+// clone returns a copy of d that shares nothing with it that either may change.
+func (d *memoryData) clone() memoryData {
+	c := *d
+
+	c.users = make(map[int]*User, len(d.users))
+	for id, user := range d.users {
+		copied := *user
+		c.users[id] = &copied
+	}
+
+	c.events = make(map[int]*Event, len(d.events))
+	for id, event := range d.events {
+		c.events[id] = copyEvent(event)
+	}
+
+	c.attendees = slices.Clone(d.attendees)
+	c.revisions = slices.Clone(d.revisions)
+	c.notifications = slices.Clone(d.notifications)
+	c.idempotencyKeys = maps.Clone(d.idempotencyKeys)
+	c.emails = slices.Clone(d.emails)
+	c.emailPrefs = maps.Clone(d.emailPrefs)
+	c.passwordResets = maps.Clone(d.passwordResets)
+	c.remindersOff = maps.Clone(d.remindersOff)
+	c.remindersSent = maps.Clone(d.remindersSent)
+	c.jobs = slices.Clone(d.jobs)
+	return c
+}
+
+type memoryTxKey struct{}
+
+// lock takes the write lock unless ctx is in a transaction on s, which already holds it.
+// It returns the function that releases the lock.
+func (s *MemoryStore) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// rlock is lock for methods that only read.
+func (s *MemoryStore) rlock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == s {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// Transact runs fn while holding the store's lock, so no other operation can interleave
+// with it, and restores the previous contents of the store if fn fails.
+func (s *MemoryStore) Transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := s.memoryData.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s)); err != nil {
+		s.memoryData = saved
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryStore) Users() UserStore {
+	return memoryUsers{s}
+}
+
+func (s *MemoryStore) Events() EventStore {
+	return memoryEvents{s}
+}
+
+func (s *MemoryStore) Attendees() AttendeeStore {
+	return memoryAttendees{s}
+}
+
+// copyEvent returns a copy of an event that shares no pointers with the original.
+func copyEvent(e *Event) *Event {
+	c := *e
+	c.StatusChangedAt = copyTime(e.StatusChangedAt)
+	c.PublishAt = copyTime(e.PublishAt)
+	c.DeletedAt = copyTime(e.DeletedAt)
+	return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := t.UTC()
+	return &c
+}
+
+type memoryUsers struct {
+	s *MemoryStore
+}
+
+func (m memoryUsers) Insert(ctx context.Context, user *User) error {
+	defer m.s.lock(ctx)()
+
+	for _, existing := range m.s.users {
+		if existing.Email == user.Email {
+			return errDuplicateEmail
+		}
+	}
+
+	m.s.nextUserId++
+	user.Id = m.s.nextUserId
+
+	stored := *user
+	m.s.users[user.Id] = &stored
+	return nil
+}
+
+func (m memoryUsers) GetById(ctx context.Context, id int) (*User, error) {
+	defer m.s.rlock(ctx)()
+
+	user, ok := m.s.users[id]
+	if !ok {
+		return nil, nil
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (m memoryUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	defer m.s.rlock(ctx)()
+
+	user := m.s.userByEmail(email)
+	if user == nil {
+		return nil, nil
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (m memoryUsers) UpdatePassword(ctx context.Context, id int, hash string) error {
+	defer m.s.lock(ctx)()
+
+	if user, ok := m.s.users[id]; ok {
+		user.Password = hash
+	}
+	return nil
+}
+
+// userByEmail must be called with the lock held.
+func (s *MemoryStore) userByEmail(email string) *User {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+type memoryEvents struct {
+	s *MemoryStore
+}
+
+// live returns a stored event that has not been deleted. It must be called with the lock held.
+func (m memoryEvents) live(id int) *Event {
+	event, ok := m.s.events[id]
+	if !ok || event.DeletedAt != nil {
+		return nil
+	}
+	return event
+}
+
+// sorted returns copies of the stored events that match, ordered by ID. It must be called
+// with the lock held.
+func (m memoryEvents) sorted(match func(*Event) bool) []*Event {
+	events := []*Event{}
+	for _, event := range m.s.events {
+		if match(event) {
+			events = append(events, copyEvent(event))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Id < events[j].Id
+	})
+	return events
+}
+
+func (m memoryEvents) Insert(ctx context.Context, event *Event) error {
+	defer m.s.lock(ctx)()
+
+	if event.Status == "" {
+		event.Status = EventStatusPublished
+	}
+
+	m.s.nextEventId++
+	event.Id = m.s.nextEventId
+	event.Version = 1
+
+	stored := copyEvent(event)
+	stored.StatusReason, stored.StatusChangedAt, stored.DeletedAt = "", nil, nil
+	m.s.events[event.Id] = stored
+	return nil
+}
+
+func (m memoryEvents) GetAll(ctx context.Context, viewerId int) ([]*Event, error) {
+	defer m.s.rlock(ctx)()
+
+	return m.sorted(func(e *Event) bool {
+		return e.DeletedAt == nil && e.VisibleTo(viewerId)
+	}), nil
+}
+
+func (m memoryEvents) GetById(ctx context.Context, id int) (*Event, error) {
+	defer m.s.rlock(ctx)()
+
+	event := m.live(id)
+	if event == nil {
+		return nil, nil
+	}
+	return copyEvent(event), nil
+}
+
+func (m memoryEvents) Update(ctx context.Context, event *Event) error {
+	defer m.s.lock(ctx)()
+
+	stored := m.live(event.Id)
+	if stored == nil || stored.Version != event.Version {
+		return ErrEditConflict
+	}
+
+	stored.Name = event.Name
+	stored.Description = event.Description
+	stored.Date = event.Date
+	stored.Location = event.Location
+	stored.Version++
+
+	event.Version = stored.Version
+	return nil
+}
+
+func (m memoryEvents) UpdateFields(ctx context.Context, event *Event, changes map[string]any) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for column := range changes {
+		if !updatableEventColumns[column] {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+	}
+
+	defer m.s.lock(ctx)()
+
+	stored := m.live(event.Id)
+	if stored == nil || stored.Version != event.Version {
+		return ErrEditConflict
+	}
+
+	for column, value := range changes {
+		switch column {
+		case "name":
+			stored.Name = fmt.Sprint(value)
+		case "description":
+			stored.Description = fmt.Sprint(value)
+		case "date":
+			stored.Date = fmt.Sprint(value)
+		case "location":
+			stored.Location = fmt.Sprint(value)
+		}
+	}
+	stored.Version++
+
+	event.Version = stored.Version
+	return nil
+}
+
+func (m memoryEvents) Transition(ctx context.Context, event *Event, status, reason, date string) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	stored := m.live(event.Id)
+	if stored == nil || stored.Status != event.Status {
+		return false, nil
+	}
+
+	if date == "" {
+		date = event.Date
+	}
+
+	changedAt := time.Now().UTC()
+
+	stored.Status = status
+	stored.StatusReason = reason
+	stored.StatusChangedAt = &changedAt
+	stored.Date = date
+	if status == EventStatusPublished {
+		stored.PublishAt = nil
+	}
+	stored.Version++
+
+	event.Status = status
+	event.StatusReason = reason
+	event.StatusChangedAt = copyTime(&changedAt)
+	event.Date = date
+	if status == EventStatusPublished {
+		event.PublishAt = nil
+	}
+	event.Version = stored.Version
+
+	return true, nil
+}
+
+func (m memoryEvents) Schedule(ctx context.Context, event *Event, publishAt *time.Time) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	stored := m.live(event.Id)
+	if stored == nil || stored.Status != EventStatusDraft {
+		return false, nil
+	}
+
+	stored.PublishAt = copyTime(publishAt)
+	stored.Version++
+
+	event.PublishAt = publishAt
+	event.Version = stored.Version
+	return true, nil
+}
+
+func (m memoryEvents) PublishDue(ctx context.Context, now time.Time) ([]int, error) {
+	defer m.s.lock(ctx)()
+
+	due := m.sorted(func(e *Event) bool {
+		return e.DeletedAt == nil && e.Status == EventStatusDraft && e.PublishAt != nil && !e.PublishAt.After(now)
+	})
+
+	var ids []int
+	for _, event := range due {
+		stored := m.s.events[event.Id]
+		stored.Status = EventStatusPublished
+		stored.StatusChangedAt = copyTime(&now)
+		stored.PublishAt = nil
+		stored.Version++
+
+		ids = append(ids, event.Id)
+	}
+
+	return ids, nil
+}
+
+func (m memoryEvents) NextPublishAt(ctx context.Context) (*time.Time, error) {
+	defer m.s.rlock(ctx)()
+
+	var next *time.Time
+	for _, event := range m.s.events {
+		if event.DeletedAt != nil || event.Status != EventStatusDraft || event.PublishAt == nil {
+			continue
+		}
+		if next == nil || event.PublishAt.Before(*next) {
+			next = event.PublishAt
+		}
+	}
+
+	return copyTime(next), nil
+}
+
+func (m memoryEvents) Delete(ctx context.Context, id, version int) error {
+	defer m.s.lock(ctx)()
+
+	stored := m.live(id)
+	if stored == nil || stored.Version != version {
+		return ErrEditConflict
+	}
+
+	deletedAt := time.Now().UTC()
+	stored.DeletedAt = &deletedAt
+	stored.Version++
+	return nil
+}
+
+func (m memoryEvents) GetByAttendee(ctx context.Context, attendeeId int) ([]Event, error) {
+	defer m.s.rlock(ctx)()
+
+	var events []Event
+	for _, attendee := range m.s.attendees {
+		if attendee.UserId != attendeeId {
+			continue
+		}
+
+		event := m.live(attendee.EventId)
+		if event == nil || event.Status == EventStatusDraft {
+			continue
+		}
+		events = append(events, *copyEvent(event))
+	}
+
+	return events, nil
+}
+
+func (m memoryEvents) GetDeletedById(ctx context.Context, id int) (*Event, error) {
+	defer m.s.rlock(ctx)()
+
+	event, ok := m.s.events[id]
+	if !ok || event.DeletedAt == nil {
+		return nil, nil
+	}
+	return copyEvent(event), nil
+}
+
+func (m memoryEvents) GetDeletedByOwner(ctx context.Context, ownerId int) ([]*Event, error) {
+	defer m.s.rlock(ctx)()
+
+	events := m.sorted(func(e *Event) bool {
+		return e.OwnerId == ownerId && e.DeletedAt != nil
+	})
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].DeletedAt.After(*events[j].DeletedAt)
+	})
+	return events, nil
+}
+
+func (m memoryEvents) Restore(ctx context.Context, id int) error {
+	defer m.s.lock(ctx)()
+
+	stored, ok := m.s.events[id]
+	if !ok || stored.DeletedAt == nil {
+		return ErrRecordNotFound
+	}
+
+	stored.DeletedAt = nil
+	stored.Version++
+	return nil
+}
+
+func (m memoryEvents) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	defer m.s.lock(ctx)()
+
+	var purged int64
+	for id, event := range m.s.events {
+		if event.DeletedAt != nil && !event.DeletedAt.After(cutoff) {
+			delete(m.s.events, id)
+			purged++
+		}
+	}
+
+	// the rows that reference an event go with it, as with ON DELETE CASCADE
+	gone := func(eventId int) bool {
+		_, ok := m.s.events[eventId]
+		return !ok
+	}
+	m.s.attendees = slices.DeleteFunc(m.s.attendees, func(a Attendee) bool { return gone(a.EventId) })
+	m.s.revisions = slices.DeleteFunc(m.s.revisions, func(r EventRevision) bool { return gone(r.EventId) })
+	m.s.notifications = slices.DeleteFunc(m.s.notifications, func(n Notification) bool { return gone(n.EventId) })
+	maps.DeleteFunc(m.s.remindersSent, func(id reminderId, _ bool) bool { return gone(id.eventId) })
+
+	return purged, nil
+}
+
+type memoryAttendees struct {
+	s *MemoryStore
+}
+
+func (m memoryAttendees) Insert(ctx context.Context, attendee *Attendee) (*Attendee, error) {
+	defer m.s.lock(ctx)()
+
+	m.s.nextAttendeeId++
+	attendee.Id = m.s.nextAttendeeId
+
+	m.s.attendees = append(m.s.attendees, *attendee)
+	return attendee, nil
+}
+
+func (m memoryAttendees) GetByEventAndAttendee(ctx context.Context, eventId, userId int) (*Attendee, error) {
+	defer m.s.rlock(ctx)()
+
+	for _, attendee := range m.s.attendees {
+		if attendee.EventId == eventId && attendee.UserId == userId {
+			found := attendee
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m memoryAttendees) GetAttendeesByEvent(ctx context.Context, eventId int) ([]User, error) {
+	defer m.s.rlock(ctx)()
+
+	var users []User
+	for _, attendee := range m.s.attendees {
+		if attendee.EventId != eventId {
+			continue
+		}
+
+		if user, ok := m.s.users[attendee.UserId]; ok {
+			users = append(users, User{Id: user.Id, Name: user.Name, Email: user.Email})
+		}
+	}
+	return users, nil
+}
+
+func (m memoryAttendees) Delete(ctx context.Context, userId, eventId int) error {
+	defer m.s.lock(ctx)()
+
+	m.s.attendees, _ = removeAttendee(m.s.attendees, userId, eventId)
+	return nil
+}
+
+// ApplyChanges works on a copy of the attendees and only keeps it if the batch commits,
+// which gives it the same all-or-nothing behaviour as the SQLite transaction.
+func (m memoryAttendees) ApplyChanges(ctx context.Context, eventId int, changes []AttendeeChange, atomic bool) ([]AttendeeChangeResult, bool, error) {
+	defer m.s.lock(ctx)()
+
+	attendees := append([]Attendee(nil), m.s.attendees...)
+	nextId := m.s.nextAttendeeId
+
+	results := make([]AttendeeChangeResult, 0, len(changes))
+	failed := false
+
+	for _, change := range changes {
+		result := AttendeeChangeResult{AttendeeChange: change}
+
+		var user *User
+		if change.UserId != 0 {
+			user = m.s.users[change.UserId]
+		} else {
+			user = m.s.userByEmail(change.Email)
+		}
+
+		switch {
+		case user == nil:
+			result.Status = AttendeeStatusUserNotFound
+		case change.Action == AttendeeActionRemove:
+			var removed bool
+			attendees, removed = removeAttendee(attendees, user.Id, eventId)
+			result.Status = AttendeeStatusNotAttending
+			if removed {
+				result.Status = AttendeeStatusRemoved
+			}
+		case hasAttendee(attendees, user.Id, eventId):
+			result.Status = AttendeeStatusAlreadyAttending
+		default:
+			nextId++
+			attendees = append(attendees, Attendee{Id: nextId, UserId: user.Id, EventId: eventId})
+			result.Status = AttendeeStatusAdded
+		}
+
+		if user != nil {
+			result.UserId = user.Id
+		}
+
+		if !result.Succeeded() {
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	if atomic && failed {
+		for i := range results {
+			if results[i].Succeeded() {
+				results[i].Status = AttendeeStatusRolledBack
+			}
+		}
+		return results, false, nil
+	}
+
+	m.s.attendees = attendees
+	m.s.nextAttendeeId = nextId
+	return results, true, nil
+}
+
+func hasAttendee(attendees []Attendee, userId, eventId int) bool {
+	for _, attendee := range attendees {
+		if attendee.UserId == userId && attendee.EventId == eventId {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAttendee removes every row for the user and event, reporting whether there were any.
+func removeAttendee(attendees []Attendee, userId, eventId int) ([]Attendee, bool) {
+	kept := attendees[:0]
+	for _, attendee := range attendees {
+		if attendee.UserId != userId || attendee.EventId != eventId {
+			kept = append(kept, attendee)
+		}
+	}
+	return kept, len(kept) < len(attendees)
+}
@@ -0,0 +1,671 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+)
+
+// The stores below keep the rest of the models in a MemoryStore. Like the stores in
+// memory.go they mirror the SQL models, including the defaults the schema fills in.
+
+type memoryRevisions struct {
+	s *MemoryStore
+}
+
+func (m memoryRevisions) Insert(ctx context.Context, event *Event, createdBy int) (*EventRevision, error) {
+	defer m.s.lock(ctx)()
+
+	number := 0
+	for _, revision := range m.s.revisions {
+		if revision.EventId == event.Id {
+			number = max(number, revision.Revision)
+		}
+	}
+
+	m.s.nextRevisionId++
+	revision := EventRevision{
+		Id:          m.s.nextRevisionId,
+		EventId:     event.Id,
+		Revision:    number + 1,
+		Name:        event.Name,
+		Description: event.Description,
+		Date:        event.Date,
+		Location:    event.Location,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	m.s.revisions = append(m.s.revisions, revision)
+
+	return &revision, nil
+}
+
+func (m memoryRevisions) GetByEvent(ctx context.Context, eventId int) ([]*EventRevision, error) {
+	defer m.s.rlock(ctx)()
+
+	revisions := []*EventRevision{}
+	for _, revision := range m.s.revisions {
+		if revision.EventId == eventId {
+			found := revision
+			revisions = append(revisions, &found)
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision > revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+func (m memoryRevisions) Get(ctx context.Context, eventId, revisionNumber int) (*EventRevision, error) {
+	defer m.s.rlock(ctx)()
+
+	for _, revision := range m.s.revisions {
+		if revision.EventId == eventId && revision.Revision == revisionNumber {
+			found := revision
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m memoryRevisions) Count(ctx context.Context, eventId int) (int, error) {
+	defer m.s.rlock(ctx)()
+
+	count := 0
+	for _, revision := range m.s.revisions {
+		if revision.EventId == eventId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryNotifications struct {
+	s *MemoryStore
+}
+
+// insert must be called with the lock held.
+func (m memoryNotifications) insert(notification *Notification) {
+	m.s.nextNotificationId++
+	notification.Id = m.s.nextNotificationId
+	notification.CreatedAt = time.Now().UTC()
+	notification.ReadAt = nil
+
+	m.s.notifications = append(m.s.notifications, *notification)
+}
+
+func (m memoryNotifications) Insert(ctx context.Context, notification *Notification) error {
+	defer m.s.lock(ctx)()
+
+	m.insert(notification)
+	return nil
+}
+
+func (m memoryNotifications) InsertForAttendees(ctx context.Context, eventId int, notificationType, message string) (int64, error) {
+	defer m.s.lock(ctx)()
+
+	event, ok := m.s.events[eventId]
+	if !ok {
+		return 0, nil
+	}
+
+	var inserted int64
+	for _, attendee := range slices.Clone(m.s.attendees) {
+		if attendee.EventId != eventId || attendee.UserId == event.OwnerId {
+			continue
+		}
+
+		m.insert(&Notification{UserId: attendee.UserId, EventId: eventId, Type: notificationType, Message: message})
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (m memoryNotifications) GetByUser(ctx context.Context, userId int, filter NotificationFilter) ([]*Notification, error) {
+	defer m.s.rlock(ctx)()
+
+	notifications := []*Notification{}
+	for i := len(m.s.notifications) - 1; i >= 0 && len(notifications) < filter.Limit; i-- {
+		n := m.s.notifications[i]
+		if n.UserId != userId || (filter.UnreadOnly && n.ReadAt != nil) || (filter.Before > 0 && n.Id >= filter.Before) {
+			continue
+		}
+
+		n.ReadAt = copyTime(n.ReadAt)
+		notifications = append(notifications, &n)
+	}
+	return notifications, nil
+}
+
+func (m memoryNotifications) CountUnread(ctx context.Context, userId int) (int, error) {
+	defer m.s.rlock(ctx)()
+
+	count := 0
+	for _, n := range m.s.notifications {
+		if n.UserId == userId && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m memoryNotifications) MarkRead(ctx context.Context, userId, id int) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	for i := range m.s.notifications {
+		n := &m.s.notifications[i]
+		if n.Id != id || n.UserId != userId {
+			continue
+		}
+
+		if n.ReadAt == nil {
+			now := time.Now().UTC()
+			n.ReadAt = &now
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m memoryNotifications) MarkAllRead(ctx context.Context, userId int) (int64, error) {
+	defer m.s.lock(ctx)()
+
+	now := time.Now().UTC()
+
+	var marked int64
+	for i := range m.s.notifications {
+		n := &m.s.notifications[i]
+		if n.UserId == userId && n.ReadAt == nil {
+			n.ReadAt = copyTime(&now)
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+type idempotencyKeyId struct {
+	userId int
+	key    string
+}
+
+type memoryIdempotencyKeys struct {
+	s *MemoryStore
+}
+
+func (m memoryIdempotencyKeys) Get(ctx context.Context, userId int, key string) (*IdempotencyKey, error) {
+	defer m.s.rlock(ctx)()
+
+	k, ok := m.s.idempotencyKeys[idempotencyKeyId{userId, key}]
+	if !ok || !k.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+
+	k.ResponseHeaders = k.ResponseHeaders.Clone()
+	k.ResponseBody = slices.Clone(k.ResponseBody)
+	return &k, nil
+}
+
+func (m memoryIdempotencyKeys) Insert(ctx context.Context, k *IdempotencyKey) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	id := idempotencyKeyId{k.UserId, k.Key}
+	if existing, ok := m.s.idempotencyKeys[id]; ok && existing.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+
+	m.s.idempotencyKeys[id] = IdempotencyKey{
+		UserId:      k.UserId,
+		Key:         k.Key,
+		Fingerprint: k.Fingerprint,
+		ExpiresAt:   k.ExpiresAt.UTC(),
+	}
+	return true, nil
+}
+
+func (m memoryIdempotencyKeys) Complete(ctx context.Context, k *IdempotencyKey) error {
+	defer m.s.lock(ctx)()
+
+	id := idempotencyKeyId{k.UserId, k.Key}
+	stored, ok := m.s.idempotencyKeys[id]
+	if !ok {
+		return nil
+	}
+
+	if k.StatusCode != nil {
+		code := *k.StatusCode
+		stored.StatusCode = &code
+	}
+	stored.ContentType = k.ContentType
+	stored.ResponseHeaders = k.ResponseHeaders.Clone()
+	stored.ResponseBody = slices.Clone(k.ResponseBody)
+
+	m.s.idempotencyKeys[id] = stored
+	return nil
+}
+
+func (m memoryIdempotencyKeys) Delete(ctx context.Context, userId int, key string) error {
+	defer m.s.lock(ctx)()
+
+	delete(m.s.idempotencyKeys, idempotencyKeyId{userId, key})
+	return nil
+}
+
+func (m memoryIdempotencyKeys) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	defer m.s.lock(ctx)()
+
+	var deleted int64
+	for id, k := range m.s.idempotencyKeys {
+		if !k.ExpiresAt.After(now) {
+			delete(m.s.idempotencyKeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memoryEmails struct {
+	s *MemoryStore
+}
+
+func (m memoryEmails) Enqueue(ctx context.Context, email *Email) error {
+	defer m.s.lock(ctx)()
+
+	m.s.nextEmailId++
+	email.Id = m.s.nextEmailId
+	email.Status = EmailStatusPending
+	email.CreatedAt = time.Now().UTC()
+
+	m.s.emails = append(m.s.emails, *email)
+	return nil
+}
+
+// email returns a stored email. It must be called with the lock held.
+func (m memoryEmails) email(id int) *Email {
+	for i := range m.s.emails {
+		if m.s.emails[i].Id == id {
+			return &m.s.emails[i]
+		}
+	}
+	return nil
+}
+
+func (m memoryEmails) GetById(ctx context.Context, id int) (*Email, error) {
+	defer m.s.rlock(ctx)()
+
+	email := m.email(id)
+	if email == nil {
+		return nil, nil
+	}
+
+	found := *email
+	return &found, nil
+}
+
+func (m memoryEmails) MarkSent(ctx context.Context, id int) error {
+	defer m.s.lock(ctx)()
+
+	if email := m.email(id); email != nil {
+		email.Status = EmailStatusSent
+	}
+	return nil
+}
+
+type memoryEmailPreferences struct {
+	s *MemoryStore
+}
+
+func (m memoryEmailPreferences) Get(ctx context.Context, userId int) (*EmailPreferences, error) {
+	defer m.s.lock(ctx)()
+
+	prefs, ok := m.s.emailPrefs[userId]
+	if !ok {
+		prefs = EmailPreferences{
+			UserId:           userId,
+			Locale:           "en",
+			Invitations:      true,
+			Reminders:        true,
+			Cancellations:    true,
+			UnsubscribeToken: newUnsubscribeToken(),
+		}
+		m.s.emailPrefs[userId] = prefs
+	}
+	return &prefs, nil
+}
+
+func (m memoryEmailPreferences) GetByToken(ctx context.Context, token string) (*EmailPreferences, error) {
+	defer m.s.rlock(ctx)()
+
+	for _, prefs := range m.s.emailPrefs {
+		if prefs.UnsubscribeToken == token {
+			return &prefs, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m memoryEmailPreferences) Update(ctx context.Context, prefs *EmailPreferences) error {
+	defer m.s.lock(ctx)()
+
+	stored, ok := m.s.emailPrefs[prefs.UserId]
+	if !ok {
+		return nil
+	}
+
+	stored.Locale = prefs.Locale
+	stored.Invitations = prefs.Invitations
+	stored.Reminders = prefs.Reminders
+	stored.Cancellations = prefs.Cancellations
+
+	m.s.emailPrefs[prefs.UserId] = stored
+	return nil
+}
+
+type passwordReset struct {
+	userId    int
+	expiresAt time.Time
+}
+
+type memoryPasswordResets struct {
+	s *MemoryStore
+}
+
+func (m memoryPasswordResets) Insert(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	defer m.s.lock(ctx)()
+
+	m.s.passwordResets[tokenHash] = passwordReset{userId: userId, expiresAt: expiresAt.UTC()}
+	return nil
+}
+
+func (m memoryPasswordResets) Consume(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	defer m.s.lock(ctx)()
+
+	reset, ok := m.s.passwordResets[tokenHash]
+	if !ok || !reset.expiresAt.After(now) {
+		return 0, nil
+	}
+
+	for hash, other := range m.s.passwordResets {
+		if other.userId == reset.userId {
+			delete(m.s.passwordResets, hash)
+		}
+	}
+	return reset.userId, nil
+}
+
+type reminderId struct {
+	eventId       int
+	userId        int
+	date          string
+	offsetMinutes int
+}
+
+type memoryReminders struct {
+	s *MemoryStore
+}
+
+func (m memoryReminders) Upcoming(ctx context.Context, from, to string) ([]*Reminder, error) {
+	defer m.s.rlock(ctx)()
+
+	reminders := []*Reminder{}
+	for _, attendee := range m.s.attendees {
+		event, ok := m.s.events[attendee.EventId]
+		if !ok || event.DeletedAt != nil || event.Status != EventStatusPublished ||
+			event.Date < from || event.Date >= to || m.s.remindersOff[attendee.Id] {
+			continue
+		}
+
+		user, ok := m.s.users[attendee.UserId]
+		if !ok {
+			continue
+		}
+
+		reminders = append(reminders, &Reminder{
+			Event: &Event{Id: event.Id, OwnerId: event.OwnerId, Name: event.Name, Date: event.Date, Location: event.Location},
+			User:  &User{Id: user.Id, Name: user.Name, Email: user.Email},
+		})
+	}
+
+	sort.SliceStable(reminders, func(i, j int) bool {
+		a, b := reminders[i], reminders[j]
+		if a.Event.Date != b.Event.Date {
+			return a.Event.Date < b.Event.Date
+		}
+		if a.Event.Id != b.Event.Id {
+			return a.Event.Id < b.Event.Id
+		}
+		return a.User.Id < b.User.Id
+	})
+	return reminders, nil
+}
+
+func (m memoryReminders) Claim(ctx context.Context, eventId, userId int, date string, offsets []time.Duration) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	claimed := false
+	for _, offset := range offsets {
+		id := reminderId{eventId: eventId, userId: userId, date: date, offsetMinutes: int(offset.Minutes())}
+		if !m.s.remindersSent[id] {
+			m.s.remindersSent[id] = true
+			claimed = true
+		}
+	}
+	return claimed, nil
+}
+
+func (m memoryReminders) Enabled(ctx context.Context, eventId, userId int) (*bool, error) {
+	defer m.s.rlock(ctx)()
+
+	for _, attendee := range m.s.attendees {
+		if attendee.EventId == eventId && attendee.UserId == userId {
+			enabled := !m.s.remindersOff[attendee.Id]
+			return &enabled, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m memoryReminders) SetEnabled(ctx context.Context, eventId, userId int, enabled bool) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	found := false
+	for _, attendee := range m.s.attendees {
+		if attendee.EventId == eventId && attendee.UserId == userId {
+			m.s.remindersOff[attendee.Id] = !enabled
+			found = true
+		}
+	}
+	return found, nil
+}
+
+type memoryJobs struct {
+	s *MemoryStore
+}
+
+// job returns a stored job. It must be called with the lock held.
+func (m memoryJobs) job(id int) *Job {
+	for i := range m.s.jobs {
+		if m.s.jobs[i].Id == id {
+			return &m.s.jobs[i]
+		}
+	}
+	return nil
+}
+
+// copyJob returns a copy of a job that shares no pointers with the original.
+func copyJob(job *Job) *Job {
+	c := *job
+	c.Payload = slices.Clone(job.Payload)
+	c.LockedUntil = copyTime(job.LockedUntil)
+	c.FinishedAt = copyTime(job.FinishedAt)
+	return &c
+}
+
+func (m memoryJobs) Enqueue(ctx context.Context, job *Job) error {
+	defer m.s.lock(ctx)()
+
+	m.s.nextJobId++
+	stored := Job{
+		Id:          m.s.nextJobId,
+		Type:        job.Type,
+		Payload:     job.Payload,
+		Status:      JobStatusPending,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt.UTC(),
+		CreatedAt:   time.Now().UTC(),
+	}
+	m.s.jobs = append(m.s.jobs, *copyJob(&stored))
+
+	*job = stored
+	return nil
+}
+
+func (m memoryJobs) Claim(ctx context.Context, runner string, types []string, now time.Time, lease time.Duration, limit int) ([]*Job, error) {
+	defer m.s.lock(ctx)()
+
+	var due []*Job
+	for i := range m.s.jobs {
+		job := &m.s.jobs[i]
+		if !slices.Contains(types, job.Type) {
+			continue
+		}
+		if (job.Status == JobStatusPending && !job.RunAt.After(now)) ||
+			(job.Status == JobStatusRunning && job.LockedUntil != nil && !job.LockedUntil.After(now)) {
+			due = append(due, job)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].RunAt.Before(due[j].RunAt)
+	})
+
+	lockedUntil := now.Add(lease).UTC()
+
+	claimed := []*Job{}
+	for _, job := range due[:min(len(due), max(limit, 0))] {
+		job.Status = JobStatusRunning
+		job.LockedBy = runner
+		job.LockedUntil = copyTime(&lockedUntil)
+		job.Attempts++
+
+		claimed = append(claimed, copyJob(job))
+	}
+	return claimed, nil
+}
+
+// held returns a job runner holds the lease on. It must be called with the lock held.
+func (m memoryJobs) held(id int, runner string) *Job {
+	job := m.job(id)
+	if job == nil || job.Status != JobStatusRunning || job.LockedBy != runner {
+		return nil
+	}
+	return job
+}
+
+func (m memoryJobs) Extend(ctx context.Context, id int, runner string, now time.Time, lease time.Duration) (bool, error) {
+	defer m.s.lock(ctx)()
+
+	job := m.held(id, runner)
+	if job == nil {
+		return false, nil
+	}
+
+	lockedUntil := now.Add(lease).UTC()
+	job.LockedUntil = &lockedUntil
+	return true, nil
+}
+
+func (m memoryJobs) Complete(ctx context.Context, id int, runner string) error {
+	defer m.s.lock(ctx)()
+
+	if job := m.held(id, runner); job != nil {
+		finishedAt := time.Now().UTC()
+		job.Status = JobStatusSucceeded
+		job.LastError = ""
+		job.LockedBy, job.LockedUntil = "", nil
+		job.FinishedAt = &finishedAt
+	}
+	return nil
+}
+
+func (m memoryJobs) Fail(ctx context.Context, id int, runner, reason string, retryAt *time.Time) error {
+	defer m.s.lock(ctx)()
+
+	job := m.held(id, runner)
+	if job == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	job.LastError = reason
+	job.LockedBy, job.LockedUntil = "", nil
+	if retryAt == nil {
+		job.Status, job.RunAt, job.FinishedAt = JobStatusFailed, now, &now
+	} else {
+		job.Status, job.RunAt, job.FinishedAt = JobStatusPending, retryAt.UTC(), nil
+	}
+	return nil
+}
+
+func (m memoryJobs) NextRunAt(ctx context.Context, types []string) (*time.Time, error) {
+	defer m.s.rlock(ctx)()
+
+	var next *time.Time
+	for _, job := range m.s.jobs {
+		if job.Status == JobStatusPending && slices.Contains(types, job.Type) && (next == nil || job.RunAt.Before(*next)) {
+			next = copyTime(&job.RunAt)
+		}
+	}
+	return next, nil
+}
+
+func (m memoryJobs) GetById(ctx context.Context, id int) (*Job, error) {
+	defer m.s.rlock(ctx)()
+
+	job := m.job(id)
+	if job == nil {
+		return nil, nil
+	}
+	return copyJob(job), nil
+}
+
+func (m memoryJobs) List(ctx context.Context, filter JobFilter) ([]*Job, error) {
+	defer m.s.rlock(ctx)()
+
+	jobs := []*Job{}
+	for i := len(m.s.jobs) - 1; i >= 0 && len(jobs) < filter.Limit; i-- {
+		job := &m.s.jobs[i]
+		if (filter.Status != "" && job.Status != filter.Status) || (filter.Type != "" && job.Type != filter.Type) ||
+			(filter.Before > 0 && job.Id >= filter.Before) {
+			continue
+		}
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs, nil
+}
+
+func (m memoryJobs) Retry(ctx context.Context, id int) (*Job, error) {
+	defer m.s.lock(ctx)()
+
+	job := m.job(id)
+	if job == nil || job.Status != JobStatusFailed {
+		return nil, nil
+	}
+
+	job.Status = JobStatusPending
+	job.Attempts = 0
+	job.RunAt = time.Now().UTC()
+	job.FinishedAt = nil
+	return copyJob(job), nil
+}
+
+func (m memoryJobs) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	defer m.s.lock(ctx)()
+
+	before := len(m.s.jobs)
+	m.s.jobs = slices.DeleteFunc(m.s.jobs, func(job Job) bool {
+		return job.Status == JobStatusSucceeded && job.FinishedAt != nil && job.FinishedAt.Before(cutoff)
+	})
+	return int64(before - len(m.s.jobs)), nil
+}
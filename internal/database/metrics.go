@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+type metricsStartKey struct{}
+
+// queryMetrics is a QueryHook that records how long each model method takes and how it
+// ended.
+type queryMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewQueryMetrics registers the db_query_duration_seconds histogram, labelled by model
+// method and result, with reg and returns the QueryHook that fills it.
+func NewQueryMetrics(reg prometheus.Registerer) (QueryHook, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of database operations by model method and result.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"op", "result"})
+
+	if err := reg.Register(duration); err != nil {
+		return nil, err
+	}
+	return queryMetrics{duration: duration}, nil
+}
+
+func (m queryMetrics) BeforeQuery(ctx context.Context, op string) context.Context {
+	return context.WithValue(ctx, metricsStartKey{}, time.Now())
+}
+
+func (m queryMetrics) AfterQuery(ctx context.Context, op string, err error) {
+	start, ok := ctx.Value(metricsStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	result := "ok"
+	switch {
+	case errors.Is(err, context.Canceled):
+		result = "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "timeout"
+	case err != nil:
+		result = "error"
+	}
+
+	m.duration.WithLabelValues(op, result).Observe(time.Since(start).Seconds())
+}
+
+// RegisterPoolMetrics registers the connection pool statistics of db, such as open, idle
+// and in-use connections and time spent waiting for one, with reg.
+func RegisterPoolMetrics(reg prometheus.Registerer, db *sql.DB, dialect Dialect) error {
+	return reg.Register(collectors.NewDBStatsCollector(db, string(dialect)))
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrations holds the schema migrations of every dialect, one directory per dialect, so
+// the binaries carry them and can migrate a database from any working directory.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// NewMigrator returns a migrator that applies the embedded migrations of dialect to db.
+// Closing the migrator closes db.
+func NewMigrator(db *sql.DB, dialect Dialect) (*migrate.Migrate, error) {
+	var instance migratedb.Driver
+	var err error
+	switch dialect {
+	case Postgres:
+		instance, err = postgres.WithInstance(db, &postgres.Config{})
+	case SQLite:
+		instance, err = sqlite3.WithInstance(db, &sqlite3.Config{})
+	default:
+		err = errors.New("unsupported database dialect " + string(dialect))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := iofs.New(migrations, path.Join("migrations", string(dialect)))
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", source, string(dialect), instance)
+}
+
+// MigrateUp applies every pending migration to the database a DSN points to and reports
+// the version the database is at afterwards. It uses a connection of its own, which is
+// closed before it returns.
+func MigrateUp(dsn string) (uint, error) {
+	db, dialect, err := Open(dsn)
+	if err != nil {
+		return 0, err
+	}
+
+	m, err := NewMigrator(db, dialect)
+	if err != nil {
+		db.Close()
+		return 0, err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, err
+	}
+
+	version, _, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// LatestMigration returns the version of the newest embedded migration of dialect, which
+// is the version a fully migrated database is at.
+func LatestMigration(dialect Dialect) (uint, error) {
+	source, err := iofs.New(migrations, path.Join("migrations", string(dialect)))
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// SchemaVersion reads the migration version db is at, and whether a failed migration left
+// it dirty, from the table the migrator keeps. It returns 0 when no migration has run.
+func SchemaVersion(ctx context.Context, db *sql.DB) (version uint, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS event_revisions (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    date DATE NOT NULL,
+    location TEXT NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, revision),
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN publish_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_events_status_publish_at ON events (status, publish_at);
\ No newline at end of file
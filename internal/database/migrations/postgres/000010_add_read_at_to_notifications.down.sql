@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_user_id_read_at;
+
+ALTER TABLE notifications DROP COLUMN read_at;
\ No newline at end of file
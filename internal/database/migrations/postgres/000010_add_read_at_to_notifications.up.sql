@@ -0,0 +1,3 @@
+ALTER TABLE notifications ADD COLUMN read_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_read_at ON notifications (user_id, read_at);
\ No newline at end of file
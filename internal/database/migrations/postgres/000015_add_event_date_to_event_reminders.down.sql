@@ -0,0 +1,9 @@
+DELETE FROM event_reminders r
+USING events e
+WHERE e.id = r.event_id AND r.event_date <> to_char(e.date, 'YYYY-MM-DD');
+
+ALTER TABLE event_reminders DROP CONSTRAINT event_reminders_pkey;
+
+ALTER TABLE event_reminders ADD PRIMARY KEY (event_id, user_id, offset_minutes);
+
+ALTER TABLE event_reminders DROP COLUMN event_date;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE event_reminders ADD COLUMN event_date TEXT NOT NULL DEFAULT '';
+
+UPDATE event_reminders r SET event_date = to_char(e.date, 'YYYY-MM-DD')
+FROM events e
+WHERE e.id = r.event_id;
+
+ALTER TABLE event_reminders ALTER COLUMN event_date DROP DEFAULT;
+
+ALTER TABLE event_reminders DROP CONSTRAINT event_reminders_pkey;
+
+ALTER TABLE event_reminders ADD PRIMARY KEY (event_id, user_id, event_date, offset_minutes);
\ No newline at end of file
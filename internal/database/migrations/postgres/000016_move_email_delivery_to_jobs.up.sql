@@ -0,0 +1,12 @@
+INSERT INTO jobs (type, payload, max_attempts, run_at)
+SELECT 'send_email', '{"emailId":' || id || '}', 8, CURRENT_TIMESTAMP
+FROM email_outbox
+WHERE status = 'pending';
+
+DROP INDEX IF EXISTS idx_email_outbox_status_next_attempt_at;
+
+ALTER TABLE email_outbox DROP COLUMN attempts;
+
+ALTER TABLE email_outbox DROP COLUMN last_error;
+
+ALTER TABLE email_outbox DROP COLUMN next_attempt_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_notifications_user_id;
+
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE events DROP COLUMN status_changed_at;
+
+ALTER TABLE events DROP COLUMN status_reason;
+
+ALTER TABLE events DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+
+ALTER TABLE events ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE events ADD COLUMN status_changed_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    event_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
\ No newline at end of file
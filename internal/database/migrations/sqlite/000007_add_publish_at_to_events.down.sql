@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_status_publish_at;
+
+ALTER TABLE events DROP COLUMN publish_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_password_reset_tokens_user_id;
+
+DROP TABLE IF EXISTS password_reset_tokens;
+
+DROP INDEX IF EXISTS idx_email_outbox_status_next_attempt_at;
+
+DROP TABLE IF EXISTS email_outbox;
+
+DROP TABLE IF EXISTS email_preferences;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_reminders;
+
+ALTER TABLE attendees DROP COLUMN reminders;
\ No newline at end of file
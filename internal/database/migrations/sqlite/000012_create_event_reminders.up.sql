@@ -0,0 +1,11 @@
+ALTER TABLE attendees ADD COLUMN reminders BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS event_reminders (
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    offset_minutes INTEGER NOT NULL,
+    sent_at DATETIME NOT NULL,
+    PRIMARY KEY (event_id, user_id, offset_minutes),
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
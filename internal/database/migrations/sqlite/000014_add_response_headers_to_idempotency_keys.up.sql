@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ADD COLUMN response_headers TEXT NOT NULL DEFAULT '';
\ No newline at end of file
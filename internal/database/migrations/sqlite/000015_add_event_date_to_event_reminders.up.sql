@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS event_reminders_by_date (
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    event_date TEXT NOT NULL,
+    offset_minutes INTEGER NOT NULL,
+    sent_at DATETIME NOT NULL,
+    PRIMARY KEY (event_id, user_id, event_date, offset_minutes),
+    FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO event_reminders_by_date (event_id, user_id, event_date, offset_minutes, sent_at)
+SELECT r.event_id, r.user_id, substr(e.date, 1, 10), r.offset_minutes, r.sent_at
+FROM event_reminders r
+JOIN events e ON e.id = r.event_id;
+
+DROP TABLE event_reminders;
+
+ALTER TABLE event_reminders_by_date RENAME TO event_reminders;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE email_outbox ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE email_outbox ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE email_outbox ADD COLUMN next_attempt_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE email_outbox SET next_attempt_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_status_next_attempt_at ON email_outbox (status, next_attempt_at);
+
+DELETE FROM jobs WHERE type = 'send_email' AND status IN ('pending', 'running');
\ No newline at end of file
@@ -1,17 +1,44 @@
 package database
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AttendeeModel
+	Users            UserStore
+	Events           EventStore
+	Attendees        AttendeeStore
+	EventRevisions   EventRevisionStore
+	Notifications    NotificationStore
+	IdempotencyKeys  IdempotencyKeyStore
+	Emails           EmailStore
+	EmailPreferences EmailPreferenceStore
+	PasswordResets   PasswordResetStore
+	Reminders        ReminderStore
+	Jobs             JobStore
+
+	transactor Transactor
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *sql.DB, dialect Dialect, opts QueryOptions) Models {
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AttendeeModel{DB: db},
+		Users:            UserModel{DB: db, opts: &opts},
+		Events:           EventModel{DB: db, opts: &opts},
+		Attendees:        AttendeeModel{DB: db, opts: &opts},
+		EventRevisions:   EventRevisionModel{DB: db, opts: &opts},
+		Notifications:    NotificationModel{DB: db, opts: &opts},
+		IdempotencyKeys:  IdempotencyKeyModel{DB: db, opts: &opts},
+		Emails:           EmailModel{DB: db, opts: &opts},
+		EmailPreferences: EmailPreferenceModel{DB: db, opts: &opts},
+		PasswordResets:   PasswordResetModel{DB: db, opts: &opts},
+		Reminders:        ReminderModel{DB: db, opts: &opts},
+		Jobs:             JobModel{DB: db, opts: &opts},
+		transactor:       sqlTransactor{db: db, dialect: dialect},
 	}
-}
\ No newline at end of file
+}
+
+// Transact runs fn as a single unit of work; see Transactor.
+func (m Models) Transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.transactor.Transact(ctx, fn)
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type NotificationModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// Notification is an entry in a user's inbox. ReadAt is nil until the user marks it read.
+type Notification struct {
+	Id        int        `json:"id"`
+	UserId    int        `json:"userId"`
+	EventId   int        `json:"eventId"`
+	Type      string     `json:"type"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt"`
+}
+
+const (
+	NotificationEventCancelled   = "event_cancelled"
+	NotificationEventPostponed   = "event_postponed"
+	NotificationEventRescheduled = "event_rescheduled"
+	NotificationEventUpdated     = "event_updated"
+	NotificationEventDeleted     = "event_deleted"
+	NotificationEventReminder    = "event_reminder"
+	NotificationAttendeeAdded    = "attendee_added"
+	NotificationAttendeeRemoved  = "attendee_removed"
+)
+
+// NotificationFilter selects a page of a user's inbox, newest first. Before, if set, is
+// the ID of the last notification of the previous page.
+type NotificationFilter struct {
+	UnreadOnly bool
+	Before     int
+	Limit      int
+}
+
+// Insert queues a notification for a single user.
+func (m NotificationModel) Insert(ctx context.Context, notification *Notification) (err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.Insert")
+	defer done(&err)
+
+	query := `
+		INSERT INTO notifications (user_id, event_id, type, message)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	return conn(ctx, m.DB).QueryRowContext(ctx, query, notification.UserId, notification.EventId, notification.Type, notification.Message).
+		Scan(&notification.Id, &notification.CreatedAt)
+}
+
+// InsertForAttendees queues the same notification for every attendee of an event other
+// than its owner, who made the change being reported, returning how many were queued.
+func (m NotificationModel) InsertForAttendees(ctx context.Context, eventId int, notificationType, message string) (_ int64, err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.InsertForAttendees")
+	defer done(&err)
+
+	query := `
+		INSERT INTO notifications (user_id, event_id, type, message)
+		SELECT a.user_id, a.event_id, CAST($1 AS TEXT), CAST($2 AS TEXT)
+		FROM attendees a
+		JOIN events e ON e.id = a.event_id
+		WHERE a.event_id = $3 AND a.user_id <> e.owner_id
+	`
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, notificationType, message, eventId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetByUser returns the notifications of a user that match filter, newest first.
+func (m NotificationModel) GetByUser(ctx context.Context, userId int, filter NotificationFilter) (_ []*Notification, err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.GetByUser")
+	defer done(&err)
+
+	query := `
+		SELECT id, user_id, event_id, type, message, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1
+		AND ($2 = FALSE OR read_at IS NULL)
+		AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, userId, filter.UnreadOnly, filter.Before, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		var notification Notification
+		var readAt sql.NullTime
+
+		err := rows.Scan(&notification.Id, &notification.UserId, &notification.EventId, &notification.Type,
+			&notification.Message, &notification.CreatedAt, &readAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// CountUnread returns how many notifications a user has not read.
+func (m NotificationModel) CountUnread(ctx context.Context, userId int) (_ int, err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.CountUnread")
+	defer done(&err)
+
+	query := "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL"
+
+	var count int
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, userId).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks a notification of a user read, keeping the time it was first read. It
+// reports false if the user has no such notification.
+func (m NotificationModel) MarkRead(ctx context.Context, userId, id int) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.MarkRead")
+	defer done(&err)
+
+	query := "UPDATE notifications SET read_at = COALESCE(read_at, $1) WHERE id = $2 AND user_id = $3"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, time.Now().UTC(), id, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MarkAllRead marks every unread notification of a user read and returns how many there were.
+func (m NotificationModel) MarkAllRead(ctx context.Context, userId int) (_ int64, err error) {
+	ctx, done := m.opts.begin(ctx, "NotificationModel.MarkAllRead")
+	defer done(&err)
+
+	query := "UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, time.Now().UTC(), userId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dialect names a supported SQL database. It is also the name of the directory that
+// holds the dialect's migrations.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// ParseDSN splits a DSN into its dialect and the data source passed to the driver.
+// postgres:// and postgresql:// URLs select PostgreSQL; sqlite:// and sqlite3:// URLs and
+// plain file paths select SQLite.
+func ParseDSN(dsn string) (Dialect, string, error) {
+	scheme, rest, found := strings.Cut(dsn, "://")
+	if !found {
+		return SQLite, dsn, nil
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "sqlite", "sqlite3":
+		return SQLite, rest, nil
+	default:
+		return "", "", fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+// sqliteDefaults are the connection settings every SQLite connection gets unless the DSN
+// sets them. WAL lets readers run alongside the writer, the busy timeout makes a blocked
+// writer wait instead of failing with "database is locked", foreign keys make the
+// ON DELETE CASCADE clauses fire, and immediate transactions take the write lock up front
+// instead of failing when they later try to upgrade a read lock.
+var sqliteDefaults = []string{
+	"_journal_mode=WAL",
+	"_busy_timeout=5000",
+	"_foreign_keys=on",
+	"_txlock=immediate",
+}
+
+// Open opens the database a DSN points to and reports its dialect.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	dialect, source, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	driver := "postgres"
+	if dialect == SQLite {
+		driver = "sqlite3"
+		for _, param := range sqliteDefaults {
+			name, _, _ := strings.Cut(param, "=")
+			if !strings.Contains(source, name+"=") {
+				source = withParam(source, param)
+			}
+		}
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, "", err
+	}
+	return db, dialect, nil
+}
+
+// withParam appends a query parameter to a SQLite data source.
+func withParam(source, param string) string {
+	if strings.Contains(source, "?") {
+		return source + "&" + param
+	}
+	return source + "?" + param
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PasswordResetModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// Insert stores the hash of a password reset token for a user. Only the hash is kept, so
+// the tokens in the database cannot be used to reset passwords.
+func (m PasswordResetModel) Insert(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) (err error) {
+	ctx, done := m.opts.begin(ctx, "PasswordResetModel.Insert")
+	defer done(&err)
+
+	query := `
+		INSERT INTO password_reset_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, query, tokenHash, userId, expiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// Consume uses up the token with the given hash if it has not expired at now, along with
+// every other token of its user, and returns the user's id. It returns 0 if there is no
+// such token.
+func (m PasswordResetModel) Consume(ctx context.Context, tokenHash string, now time.Time) (_ int, err error) {
+	ctx, done := m.opts.begin(ctx, "PasswordResetModel.Consume")
+	defer done(&err)
+
+	query := `
+		DELETE FROM password_reset_tokens
+		WHERE token_hash = $1 AND expires_at > $2
+		RETURNING user_id
+	`
+
+	var userId int
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, tokenHash, now.UTC()).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = $1", userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
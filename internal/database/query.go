@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultQueryTimeout = 3 * time.Second
+	DefaultBatchTimeout = 30 * time.Second
+)
+
+// QueryOptions controls how model methods run their queries. Every method runs under the
+// context it is given, so a cancelled request also cancels its queries, and is further
+// bounded by a per-query deadline.
+type QueryOptions struct {
+	// Timeout bounds a single model method. Zero means DefaultQueryTimeout.
+	Timeout time.Duration
+	// BatchTimeout bounds bulk methods such as purges and batch changes. Zero means
+	// DefaultBatchTimeout.
+	BatchTimeout time.Duration
+	// Hooks are notified around every model method, in order.
+	Hooks []QueryHook
+}
+
+// QueryHook observes model methods, for example to trace or time them. Operations are
+// named after the model method, such as "EventModel.GetById".
+type QueryHook interface {
+	// BeforeQuery is called before the method runs. The returned context is used for the
+	// method's queries and passed to AfterQuery.
+	BeforeQuery(ctx context.Context, op string) context.Context
+	// AfterQuery is called once the method has returned, with its error if any.
+	AfterQuery(ctx context.Context, op string, err error)
+}
+
+// begin prepares the context for a model method and returns it with a function that must
+// be deferred with a pointer to the method's error. A nil QueryOptions uses the defaults.
+func (o *QueryOptions) begin(ctx context.Context, op string) (context.Context, func(*error)) {
+	timeout := DefaultQueryTimeout
+	if o != nil && o.Timeout > 0 {
+		timeout = o.Timeout
+	}
+	return o.beginWithTimeout(ctx, op, timeout)
+}
+
+// beginBatch is begin for bulk methods, which use the longer batch timeout.
+func (o *QueryOptions) beginBatch(ctx context.Context, op string) (context.Context, func(*error)) {
+	timeout := DefaultBatchTimeout
+	if o != nil && o.BatchTimeout > 0 {
+		timeout = o.BatchTimeout
+	}
+	return o.beginWithTimeout(ctx, op, timeout)
+}
+
+func (o *QueryOptions) beginWithTimeout(ctx context.Context, op string, timeout time.Duration) (context.Context, func(*error)) {
+	var hooks []QueryHook
+	if o != nil {
+		hooks = o.Hooks
+	}
+
+	for _, hook := range hooks {
+		ctx = hook.BeforeQuery(ctx, op)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	return queryCtx, func(err *error) {
+		cancel()
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i].AfterQuery(ctx, op, *err)
+		}
+	}
+}
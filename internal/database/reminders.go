@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ReminderModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+// Reminder is an attendee of a published event who has not turned its reminders off.
+type Reminder struct {
+	Event *Event
+	User  *User
+}
+
+// Upcoming returns a reminder for every attendee of the published events dated on or after
+// from and before to, whether or not they have already been reminded. Dates are written
+// as 2006-01-02.
+func (m ReminderModel) Upcoming(ctx context.Context, from, to string) (_ []*Reminder, err error) {
+	ctx, done := m.opts.beginBatch(ctx, "ReminderModel.Upcoming")
+	defer done(&err)
+
+	query := `
+		SELECT e.id, e.owner_id, e.name, e.date, e.location, u.id, u.name, u.email
+		FROM events e
+		JOIN attendees a ON a.event_id = e.id
+		JOIN users u ON u.id = a.user_id
+		WHERE e.status = $1 AND e.deleted_at IS NULL AND e.date >= $2 AND e.date < $3 AND a.reminders
+		ORDER BY e.date, e.id, u.id
+	`
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, EventStatusPublished, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []*Reminder{}
+
+	for rows.Next() {
+		reminder := &Reminder{Event: &Event{}, User: &User{}}
+
+		err := rows.Scan(&reminder.Event.Id, &reminder.Event.OwnerId, &reminder.Event.Name, &reminder.Event.Date,
+			&reminder.Event.Location, &reminder.User.Id, &reminder.User.Name, &reminder.User.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		reminders = append(reminders, reminder)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+// Claim records that a user is being reminded of an event on date, written as 2006-01-02,
+// at each of offsets before it starts, and reports whether any of them had not been
+// recorded yet. The record is what keeps a reminder from being sent twice, by another
+// instance or after a restart, so the reminder should be queued in the same transaction.
+// Claims are kept per date, so an event that is moved to another date is reminded of
+// again. Offsets must be in the same order on every call, so concurrent claims of the same
+// reminder wait for each other instead of each taking a part of it.
+func (m ReminderModel) Claim(ctx context.Context, eventId, userId int, date string, offsets []time.Duration) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "ReminderModel.Claim")
+	defer done(&err)
+
+	query := `
+		INSERT INTO event_reminders (event_id, user_id, event_date, offset_minutes, sent_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_id, user_id, event_date, offset_minutes) DO NOTHING
+	`
+
+	claimed := false
+	for _, offset := range offsets {
+		result, err := conn(ctx, m.DB).ExecContext(ctx, query, eventId, userId, date, int(offset.Minutes()), time.Now().UTC())
+		if err != nil {
+			return false, err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		claimed = claimed || inserted > 0
+	}
+
+	return claimed, nil
+}
+
+// Enabled reports whether a user wants reminders of an event, or returns nil if they do
+// not attend it.
+func (m ReminderModel) Enabled(ctx context.Context, eventId, userId int) (_ *bool, err error) {
+	ctx, done := m.opts.begin(ctx, "ReminderModel.Enabled")
+	defer done(&err)
+
+	query := "SELECT reminders FROM attendees WHERE event_id = $1 AND user_id = $2"
+
+	var enabled bool
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, eventId, userId).Scan(&enabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &enabled, nil
+}
+
+// SetEnabled turns a user's reminders of an event on or off, and reports false if they do
+// not attend it.
+func (m ReminderModel) SetEnabled(ctx context.Context, eventId, userId int, enabled bool) (_ bool, err error) {
+	ctx, done := m.opts.begin(ctx, "ReminderModel.SetEnabled")
+	defer done(&err)
+
+	query := "UPDATE attendees SET reminders = $1 WHERE event_id = $2 AND user_id = $3"
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, query, enabled, eventId, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type EventRevisionModel struct {
+	DB   *sql.DB
+	opts *QueryOptions
+}
+
+type EventRevision struct {
+	Id          int       `json:"id"`
+	EventId     int       `json:"eventId"`
+	Revision    int       `json:"revision"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Date        string    `json:"date"`
+	Location    string    `json:"location"`
+	CreatedBy   int       `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Insert stores a snapshot of the event as the next revision number for that event.
+func (m EventRevisionModel) Insert(ctx context.Context, event *Event, createdBy int) (_ *EventRevision, err error) {
+	ctx, done := m.opts.begin(ctx, "EventRevisionModel.Insert")
+	defer done(&err)
+
+	query := `
+		INSERT INTO event_revisions (event_id, revision, name, description, date, location, created_by)
+		VALUES ($1, (SELECT COALESCE(MAX(revision), 0) + 1 FROM event_revisions WHERE event_id = $1), $2, $3, $4, $5, $6)
+		RETURNING id, revision, created_at
+	`
+
+	revision := EventRevision{
+		EventId:     event.Id,
+		Name:        event.Name,
+		Description: event.Description,
+		Date:        event.Date,
+		Location:    event.Location,
+		CreatedBy:   createdBy,
+	}
+
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, event.Id, event.Name, event.Description, event.Date, event.Location, createdBy).
+		Scan(&revision.Id, &revision.Revision, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+func (m EventRevisionModel) GetByEvent(ctx context.Context, eventId int) (_ []*EventRevision, err error) {
+	ctx, done := m.opts.begin(ctx, "EventRevisionModel.GetByEvent")
+	defer done(&err)
+
+	query := `
+		SELECT id, event_id, revision, name, description, date, location, created_by, created_at
+		FROM event_revisions
+		WHERE event_id = $1
+		ORDER BY revision DESC
+	`
+
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*EventRevision{}
+
+	for rows.Next() {
+		var revision EventRevision
+		err := rows.Scan(&revision.Id, &revision.EventId, &revision.Revision, &revision.Name, &revision.Description,
+			&revision.Date, &revision.Location, &revision.CreatedBy, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m EventRevisionModel) Get(ctx context.Context, eventId, revisionNumber int) (_ *EventRevision, err error) {
+	ctx, done := m.opts.begin(ctx, "EventRevisionModel.Get")
+	defer done(&err)
+
+	query := `
+		SELECT id, event_id, revision, name, description, date, location, created_by, created_at
+		FROM event_revisions
+		WHERE event_id = $1 AND revision = $2
+	`
+
+	var revision EventRevision
+	err = conn(ctx, m.DB).QueryRowContext(ctx, query, eventId, revisionNumber).
+		Scan(&revision.Id, &revision.EventId, &revision.Revision, &revision.Name, &revision.Description,
+			&revision.Date, &revision.Location, &revision.CreatedBy, &revision.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+func (m EventRevisionModel) Count(ctx context.Context, eventId int) (_ int, err error) {
+	ctx, done := m.opts.begin(ctx, "EventRevisionModel.Count")
+	defer done(&err)
+
+	query := `SELECT COUNT(*) FROM event_revisions WHERE event_id = $1`
+
+	var count int
+	if err := conn(ctx, m.DB).QueryRowContext(ctx, query, eventId).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Diff returns the fields that differ between two revisions, in the order they appear on Event.
+func (r *EventRevision) Diff(other *EventRevision) []FieldChange {
+	changes := []FieldChange{}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"name", r.Name, other.Name},
+		{"description", r.Description, other.Description},
+		{"date", r.Date, other.Date},
+		{"location", r.Location, other.Location},
+	}
+
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	return changes
+}
+
+// Event returns the revision as an Event that can be written back with EventModel.Update.
+func (r *EventRevision) Event(ownerId int) *Event {
+	return &Event{
+		Id:          r.EventId,
+		OwnerId:     ownerId,
+		Name:        r.Name,
+		Description: r.Description,
+		Date:        r.Date,
+		Location:    r.Location,
+	}
+}
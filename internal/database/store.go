@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// UserStore persists users. GetById and GetByEmail return nil, nil when there is no such user.
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	GetById(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id int, hash string) error
+}
+
+// EventStore persists events. Lookups return nil, nil when there is no such event, and
+// writes guarded by a version return ErrEditConflict when the version is stale.
+type EventStore interface {
+	Insert(ctx context.Context, event *Event) error
+	GetAll(ctx context.Context, viewerId int) ([]*Event, error)
+	GetById(ctx context.Context, id int) (*Event, error)
+	Update(ctx context.Context, event *Event) error
+	UpdateFields(ctx context.Context, event *Event, changes map[string]any) error
+	Transition(ctx context.Context, event *Event, status, reason, date string) (bool, error)
+	Schedule(ctx context.Context, event *Event, publishAt *time.Time) (bool, error)
+	PublishDue(ctx context.Context, now time.Time) ([]int, error)
+	NextPublishAt(ctx context.Context) (*time.Time, error)
+	Delete(ctx context.Context, id, version int) error
+	GetByAttendee(ctx context.Context, attendeeId int) ([]Event, error)
+	GetDeletedById(ctx context.Context, id int) (*Event, error)
+	GetDeletedByOwner(ctx context.Context, ownerId int) ([]*Event, error)
+	Restore(ctx context.Context, id int) error
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// AttendeeStore persists which users attend which events.
+type AttendeeStore interface {
+	Insert(ctx context.Context, attendee *Attendee) (*Attendee, error)
+	GetByEventAndAttendee(ctx context.Context, eventId, userId int) (*Attendee, error)
+	GetAttendeesByEvent(ctx context.Context, eventId int) ([]User, error)
+	Delete(ctx context.Context, userId, eventId int) error
+	ApplyChanges(ctx context.Context, eventId int, changes []AttendeeChange, atomic bool) ([]AttendeeChangeResult, bool, error)
+}
+
+// EventRevisionStore persists snapshots of events. Get returns nil, nil when there is no
+// such revision.
+type EventRevisionStore interface {
+	Insert(ctx context.Context, event *Event, createdBy int) (*EventRevision, error)
+	GetByEvent(ctx context.Context, eventId int) ([]*EventRevision, error)
+	Get(ctx context.Context, eventId, revisionNumber int) (*EventRevision, error)
+	Count(ctx context.Context, eventId int) (int, error)
+}
+
+// NotificationStore persists users' notification inboxes.
+type NotificationStore interface {
+	Insert(ctx context.Context, notification *Notification) error
+	InsertForAttendees(ctx context.Context, eventId int, notificationType, message string) (int64, error)
+	GetByUser(ctx context.Context, userId int, filter NotificationFilter) ([]*Notification, error)
+	CountUnread(ctx context.Context, userId int) (int, error)
+	MarkRead(ctx context.Context, userId, id int) (bool, error)
+	MarkAllRead(ctx context.Context, userId int) (int64, error)
+}
+
+// IdempotencyKeyStore persists Idempotency-Key headers and the responses they replay.
+type IdempotencyKeyStore interface {
+	Get(ctx context.Context, userId int, key string) (*IdempotencyKey, error)
+	Insert(ctx context.Context, k *IdempotencyKey) (bool, error)
+	Complete(ctx context.Context, k *IdempotencyKey) error
+	Delete(ctx context.Context, userId int, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// EmailStore persists the outbox of rendered emails.
+type EmailStore interface {
+	Enqueue(ctx context.Context, email *Email) error
+	GetById(ctx context.Context, id int) (*Email, error)
+	MarkSent(ctx context.Context, id int) error
+}
+
+// EmailPreferenceStore persists users' email settings.
+type EmailPreferenceStore interface {
+	Get(ctx context.Context, userId int) (*EmailPreferences, error)
+	GetByToken(ctx context.Context, token string) (*EmailPreferences, error)
+	Update(ctx context.Context, prefs *EmailPreferences) error
+}
+
+// PasswordResetStore persists hashed password reset tokens.
+type PasswordResetStore interface {
+	Insert(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error
+	Consume(ctx context.Context, tokenHash string, now time.Time) (int, error)
+}
+
+// ReminderStore persists which reminders attendees want and which have been sent.
+type ReminderStore interface {
+	Upcoming(ctx context.Context, from, to string) ([]*Reminder, error)
+	Claim(ctx context.Context, eventId, userId int, date string, offsets []time.Duration) (bool, error)
+	Enabled(ctx context.Context, eventId, userId int) (*bool, error)
+	SetEnabled(ctx context.Context, eventId, userId int, enabled bool) (bool, error)
+}
+
+// JobStore persists the background job queue. GetById and Retry return nil, nil when there
+// is no such job.
+type JobStore interface {
+	Enqueue(ctx context.Context, job *Job) error
+	Claim(ctx context.Context, runner string, types []string, now time.Time, lease time.Duration, limit int) ([]*Job, error)
+	Extend(ctx context.Context, id int, runner string, now time.Time, lease time.Duration) (bool, error)
+	Complete(ctx context.Context, id int, runner string) error
+	Fail(ctx context.Context, id int, runner, reason string, retryAt *time.Time) error
+	NextRunAt(ctx context.Context, types []string) (*time.Time, error)
+	GetById(ctx context.Context, id int) (*Job, error)
+	List(ctx context.Context, filter JobFilter) ([]*Job, error)
+	Retry(ctx context.Context, id int) (*Job, error)
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+var (
+	_ UserStore            = UserModel{}
+	_ EventStore           = EventModel{}
+	_ AttendeeStore        = AttendeeModel{}
+	_ EventRevisionStore   = EventRevisionModel{}
+	_ NotificationStore    = NotificationModel{}
+	_ IdempotencyKeyStore  = IdempotencyKeyModel{}
+	_ EmailStore           = EmailModel{}
+	_ EmailPreferenceStore = EmailPreferenceModel{}
+	_ PasswordResetStore   = PasswordResetModel{}
+	_ ReminderStore        = ReminderModel{}
+	_ JobStore             = JobModel{}
+)
@@ -0,0 +1,551 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-event-crud/internal/database"
+)
+
+// closeTo reports whether two times are within a millisecond, since stores keep times at
+// different precisions.
+func closeTo(got, want time.Time) bool {
+	return got.Sub(want).Abs() < time.Millisecond
+}
+
+func attend(t *testing.T, m database.Models, eventId int, users ...*database.User) {
+	t.Helper()
+
+	for _, user := range users {
+		if _, err := m.Attendees.Insert(context.Background(), &database.Attendee{EventId: eventId, UserId: user.Id}); err != nil {
+			t.Fatalf("insert attendee: %v", err)
+		}
+	}
+}
+
+func testRevisionsNumbering(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	first := createEvent(t, m, owner.Id, "")
+	second := createEvent(t, m, owner.Id, "")
+
+	for _, name := range []string{"First draft", "Second draft"} {
+		first.Name = name
+		if _, err := m.EventRevisions.Insert(ctx, first, owner.Id); err != nil {
+			t.Fatalf("insert revision: %v", err)
+		}
+	}
+
+	other, err := m.EventRevisions.Insert(ctx, second, owner.Id)
+	if err != nil || other.Revision != 1 {
+		t.Fatalf("first revision of another event = %+v, %v; want revision 1", other, err)
+	}
+
+	revisions, err := m.EventRevisions.GetByEvent(ctx, first.Id)
+	if err != nil || len(revisions) != 2 || revisions[0].Revision != 2 || revisions[0].Name != "Second draft" || revisions[1].Revision != 1 {
+		t.Fatalf("GetByEvent = %+v, %v; want revisions 2 and 1, newest first", revisions, err)
+	}
+
+	revision, err := m.EventRevisions.Get(ctx, first.Id, 1)
+	if err != nil || revision == nil || revision.Name != "First draft" || revision.CreatedBy != owner.Id {
+		t.Fatalf("Get = %+v, %v; want the first draft", revision, err)
+	}
+
+	if missing, err := m.EventRevisions.Get(ctx, first.Id, 3); missing != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	if count, err := m.EventRevisions.Count(ctx, first.Id); count != 2 || err != nil {
+		t.Fatalf("Count = %d, %v; want 2", count, err)
+	}
+}
+
+func testNotificationsInbox(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	bob := createUser(t, m, "bob")
+	event := createEvent(t, m, owner.Id, "")
+	attend(t, m, event.Id, owner, alice, bob)
+
+	queued, err := m.Notifications.InsertForAttendees(ctx, event.Id, database.NotificationEventUpdated, "Updated")
+	if err != nil || queued != 2 {
+		t.Fatalf("InsertForAttendees = %d, %v; want 2, leaving out the owner", queued, err)
+	}
+
+	latest := &database.Notification{UserId: alice.Id, EventId: event.Id, Type: database.NotificationEventCancelled, Message: "Cancelled"}
+	if err := m.Notifications.Insert(ctx, latest); err != nil || latest.Id == 0 || latest.CreatedAt.IsZero() {
+		t.Fatalf("Insert = %+v, %v; want an id and creation time", latest, err)
+	}
+
+	inbox, err := m.Notifications.GetByUser(ctx, alice.Id, database.NotificationFilter{Limit: 10})
+	if err != nil || len(inbox) != 2 || inbox[0].Id != latest.Id || inbox[1].Type != database.NotificationEventUpdated {
+		t.Fatalf("GetByUser = %+v, %v; want both notifications, newest first", inbox, err)
+	}
+
+	page, err := m.Notifications.GetByUser(ctx, alice.Id, database.NotificationFilter{Before: latest.Id, Limit: 10})
+	if err != nil || len(page) != 1 || page[0].Id != inbox[1].Id {
+		t.Fatalf("GetByUser(before) = %+v, %v; want the older notification", page, err)
+	}
+
+	bobs, err := m.Notifications.GetByUser(ctx, bob.Id, database.NotificationFilter{Limit: 10})
+	if err != nil || len(bobs) != 1 {
+		t.Fatalf("GetByUser(bob) = %+v, %v; want one notification", bobs, err)
+	}
+
+	if marked, err := m.Notifications.MarkRead(ctx, alice.Id, bobs[0].Id); marked || err != nil {
+		t.Fatalf("MarkRead(another user's) = %v, %v; want false", marked, err)
+	}
+
+	if marked, err := m.Notifications.MarkRead(ctx, alice.Id, latest.Id); !marked || err != nil {
+		t.Fatalf("MarkRead = %v, %v; want true", marked, err)
+	}
+
+	unread, err := m.Notifications.GetByUser(ctx, alice.Id, database.NotificationFilter{UnreadOnly: true, Limit: 10})
+	if err != nil || len(unread) != 1 || unread[0].ReadAt != nil {
+		t.Fatalf("GetByUser(unread) = %+v, %v; want one unread notification", unread, err)
+	}
+
+	if count, err := m.Notifications.CountUnread(ctx, alice.Id); count != 1 || err != nil {
+		t.Fatalf("CountUnread = %d, %v; want 1", count, err)
+	}
+
+	if marked, err := m.Notifications.MarkAllRead(ctx, alice.Id); marked != 1 || err != nil {
+		t.Fatalf("MarkAllRead = %d, %v; want 1", marked, err)
+	}
+
+	if count, err := m.Notifications.CountUnread(ctx, bob.Id); count != 1 || err != nil {
+		t.Fatalf("CountUnread(bob) = %d, %v; want 1, untouched by alice", count, err)
+	}
+}
+
+func testIdempotencyKeysLifecycle(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+	key := &database.IdempotencyKey{UserId: alice.Id, Key: "k1", Fingerprint: "fp", ExpiresAt: time.Now().Add(time.Hour)}
+
+	if claimed, err := m.IdempotencyKeys.Insert(ctx, key); !claimed || err != nil {
+		t.Fatalf("Insert = %v, %v; want true", claimed, err)
+	}
+
+	if claimed, err := m.IdempotencyKeys.Insert(ctx, key); claimed || err != nil {
+		t.Fatalf("Insert(held key) = %v, %v; want false", claimed, err)
+	}
+
+	pending, err := m.IdempotencyKeys.Get(ctx, alice.Id, "k1")
+	if err != nil || pending == nil || pending.StatusCode != nil || pending.Fingerprint != "fp" {
+		t.Fatalf("Get(in progress) = %+v, %v; want the key without a status", pending, err)
+	}
+
+	status := 201
+	key.StatusCode, key.ContentType, key.ResponseBody = &status, "application/json", []byte(`{"id":1}`)
+	key.ResponseHeaders = http.Header{"Etag": {`"1"`}}
+	if err := m.IdempotencyKeys.Complete(ctx, key); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	done, err := m.IdempotencyKeys.Get(ctx, alice.Id, "k1")
+	if err != nil || done == nil || done.StatusCode == nil || *done.StatusCode != 201 || string(done.ResponseBody) != `{"id":1}` ||
+		done.ResponseHeaders.Get("ETag") != `"1"` {
+		t.Fatalf("Get(completed) = %+v, %v; want the stored response", done, err)
+	}
+
+	if err := m.IdempotencyKeys.Delete(ctx, alice.Id, "k1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if released, err := m.IdempotencyKeys.Get(ctx, alice.Id, "k1"); released != nil || err != nil {
+		t.Fatalf("Get(deleted) = %+v, %v; want nil, nil", released, err)
+	}
+
+	expired := &database.IdempotencyKey{UserId: alice.Id, Key: "k2", Fingerprint: "fp", ExpiresAt: time.Now().Add(-time.Minute)}
+	if claimed, err := m.IdempotencyKeys.Insert(ctx, expired); !claimed || err != nil {
+		t.Fatalf("Insert(k2) = %v, %v; want true", claimed, err)
+	}
+	if found, err := m.IdempotencyKeys.Get(ctx, alice.Id, "k2"); found != nil || err != nil {
+		t.Fatalf("Get(expired) = %+v, %v; want nil, nil", found, err)
+	}
+
+	expired.ExpiresAt = time.Now().Add(time.Hour)
+	if claimed, err := m.IdempotencyKeys.Insert(ctx, expired); !claimed || err != nil {
+		t.Fatalf("Insert(over an expired key) = %v, %v; want true", claimed, err)
+	}
+
+	if deleted, err := m.IdempotencyKeys.DeleteExpired(ctx, time.Now().Add(2*time.Hour)); deleted != 1 || err != nil {
+		t.Fatalf("DeleteExpired = %d, %v; want 1", deleted, err)
+	}
+}
+
+func testEmailsQueue(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+
+	email := &database.Email{UserId: alice.Id, Recipient: alice.Email, Template: "invitation", Subject: "Hi", TextBody: "Hi", HTMLBody: "<p>Hi</p>"}
+	if err := m.Emails.Enqueue(ctx, email); err != nil || email.Id == 0 || email.Status != database.EmailStatusPending {
+		t.Fatalf("Enqueue = %+v, %v; want a pending email with an id", email, err)
+	}
+
+	found, err := m.Emails.GetById(ctx, email.Id)
+	if err != nil || found == nil || found.UserId != alice.Id || found.Template != "invitation" || found.HTMLBody != "<p>Hi</p>" ||
+		found.Status != database.EmailStatusPending {
+		t.Fatalf("GetById = %+v, %v; want the pending email", found, err)
+	}
+
+	if err := m.Emails.MarkSent(ctx, email.Id); err != nil {
+		t.Fatalf("MarkSent: %v", err)
+	}
+
+	if sent, err := m.Emails.GetById(ctx, email.Id); err != nil || sent == nil || sent.Status != database.EmailStatusSent {
+		t.Fatalf("GetById(sent) = %+v, %v; want it sent", sent, err)
+	}
+
+	if missing, err := m.Emails.GetById(ctx, 9999); missing != nil || err != nil {
+		t.Fatalf("GetById(missing) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testEmailPreferencesDefaults(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+
+	prefs, err := m.EmailPreferences.Get(ctx, alice.Id)
+	if err != nil || prefs == nil || prefs.Locale != "en" || !prefs.Invitations || !prefs.Reminders || !prefs.Cancellations || prefs.UnsubscribeToken == "" {
+		t.Fatalf("Get = %+v, %v; want the defaults with a token", prefs, err)
+	}
+
+	again, err := m.EmailPreferences.Get(ctx, alice.Id)
+	if err != nil || again.UnsubscribeToken != prefs.UnsubscribeToken {
+		t.Fatalf("Get(again) = %+v, %v; want the same token", again, err)
+	}
+
+	prefs.Locale, prefs.Reminders = "es", false
+	if err := m.EmailPreferences.Update(ctx, prefs); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	byToken, err := m.EmailPreferences.GetByToken(ctx, prefs.UnsubscribeToken)
+	if err != nil || byToken == nil || byToken.UserId != alice.Id || byToken.Locale != "es" || byToken.Reminders || !byToken.Invitations {
+		t.Fatalf("GetByToken = %+v, %v; want the updated preferences", byToken, err)
+	}
+
+	if missing, err := m.EmailPreferences.GetByToken(ctx, "no-such-token"); missing != nil || err != nil {
+		t.Fatalf("GetByToken(missing) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testPasswordResetsConsume(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+	bob := createUser(t, m, "bob")
+	now := time.Now()
+
+	tokens := []struct {
+		hash    string
+		userId  int
+		expires time.Time
+	}{
+		{"alice-1", alice.Id, now.Add(time.Hour)},
+		{"alice-2", alice.Id, now.Add(time.Hour)},
+		{"alice-old", alice.Id, now.Add(-time.Minute)},
+		{"bob-1", bob.Id, now.Add(time.Hour)},
+	}
+	for _, token := range tokens {
+		if err := m.PasswordResets.Insert(ctx, token.userId, token.hash, token.expires); err != nil {
+			t.Fatalf("Insert(%s): %v", token.hash, err)
+		}
+	}
+
+	if userId, err := m.PasswordResets.Consume(ctx, "alice-old", now); userId != 0 || err != nil {
+		t.Fatalf("Consume(expired) = %d, %v; want 0", userId, err)
+	}
+
+	if userId, err := m.PasswordResets.Consume(ctx, "alice-1", now); userId != alice.Id || err != nil {
+		t.Fatalf("Consume = %d, %v; want %d", userId, err, alice.Id)
+	}
+
+	if userId, err := m.PasswordResets.Consume(ctx, "alice-2", now); userId != 0 || err != nil {
+		t.Fatalf("Consume(another token of the same user) = %d, %v; want 0", userId, err)
+	}
+
+	if userId, err := m.PasswordResets.Consume(ctx, "bob-1", now); userId != bob.Id || err != nil {
+		t.Fatalf("Consume(bob) = %d, %v; want %d", userId, err, bob.Id)
+	}
+}
+
+func testRemindersUpcomingAndClaim(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	bob := createUser(t, m, "bob")
+	carol := createUser(t, m, "carol")
+
+	event := createEvent(t, m, owner.Id, "")
+	draft := createEvent(t, m, owner.Id, database.EventStatusDraft)
+	attend(t, m, event.Id, alice, bob)
+	attend(t, m, draft.Id, alice)
+
+	reminders, err := m.Reminders.Upcoming(ctx, "2030-05-01", "2030-05-02")
+	if err != nil || len(reminders) != 2 || reminders[0].User.Id != alice.Id || reminders[1].User.Id != bob.Id ||
+		reminders[0].Event.Id != event.Id || reminders[0].User.Email != alice.Email {
+		t.Fatalf("Upcoming = %+v, %v; want alice and bob for the published event", reminders, err)
+	}
+
+	if none, err := m.Reminders.Upcoming(ctx, "2030-05-02", "2030-05-03"); len(none) != 0 || err != nil {
+		t.Fatalf("Upcoming(later dates) = %+v, %v; want none", none, err)
+	}
+
+	if found, err := m.Reminders.SetEnabled(ctx, event.Id, bob.Id, false); !found || err != nil {
+		t.Fatalf("SetEnabled = %v, %v; want true", found, err)
+	}
+	if found, err := m.Reminders.SetEnabled(ctx, event.Id, carol.Id, false); found || err != nil {
+		t.Fatalf("SetEnabled(not attending) = %v, %v; want false", found, err)
+	}
+
+	if enabled, err := m.Reminders.Enabled(ctx, event.Id, bob.Id); enabled == nil || *enabled || err != nil {
+		t.Fatalf("Enabled(bob) = %v, %v; want false", enabled, err)
+	}
+	if enabled, err := m.Reminders.Enabled(ctx, event.Id, alice.Id); enabled == nil || !*enabled || err != nil {
+		t.Fatalf("Enabled(alice) = %v, %v; want true", enabled, err)
+	}
+	if enabled, err := m.Reminders.Enabled(ctx, event.Id, carol.Id); enabled != nil || err != nil {
+		t.Fatalf("Enabled(not attending) = %v, %v; want nil, nil", enabled, err)
+	}
+
+	reminders, err = m.Reminders.Upcoming(ctx, "2030-05-01", "2030-05-02")
+	if err != nil || len(reminders) != 1 || reminders[0].User.Id != alice.Id {
+		t.Fatalf("Upcoming(bob opted out) = %+v, %v; want only alice", reminders, err)
+	}
+
+	offsets := []time.Duration{time.Hour, 24 * time.Hour}
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-05-01", offsets); !claimed || err != nil {
+		t.Fatalf("Claim = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-05-01", offsets); claimed || err != nil {
+		t.Fatalf("Claim(again) = %v, %v; want false", claimed, err)
+	}
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-05-01", append(offsets, 10*time.Minute)); !claimed || err != nil {
+		t.Fatalf("Claim(new offset) = %v, %v; want true", claimed, err)
+	}
+}
+
+func testRemindersRescheduled(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	event := createEvent(t, m, owner.Id, "")
+	attend(t, m, event.Id, alice)
+
+	offsets := []time.Duration{time.Hour, 24 * time.Hour}
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-05-01", offsets); !claimed || err != nil {
+		t.Fatalf("Claim = %v, %v; want true", claimed, err)
+	}
+
+	event.Date = "2030-06-01"
+	if err := m.Events.Update(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err := m.Reminders.Upcoming(ctx, "2030-06-01", "2030-06-02")
+	if err != nil || len(reminders) != 1 || reminders[0].User.Id != alice.Id {
+		t.Fatalf("Upcoming(new date) = %+v, %v; want alice", reminders, err)
+	}
+
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-06-01", offsets); !claimed || err != nil {
+		t.Fatalf("Claim(new date) = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := m.Reminders.Claim(ctx, event.Id, alice.Id, "2030-06-01", offsets); claimed || err != nil {
+		t.Fatalf("Claim(new date again) = %v, %v; want false", claimed, err)
+	}
+}
+
+func testTransactRollbackNotifications(t *testing.T, m database.Models) {
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	event := createEvent(t, m, owner.Id, "")
+	errAbort := errors.New("abort")
+
+	err := m.Transact(context.Background(), func(ctx context.Context) error {
+		if _, err := m.EventRevisions.Insert(ctx, event, owner.Id); err != nil {
+			return err
+		}
+
+		notification := &database.Notification{UserId: alice.Id, EventId: event.Id, Type: database.NotificationEventUpdated, Message: "Updated"}
+		if err := m.Notifications.Insert(ctx, notification); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transact = %v, want the error returned by fn", err)
+	}
+
+	if count, err := m.EventRevisions.Count(context.Background(), event.Id); count != 0 || err != nil {
+		t.Fatalf("revisions after rollback = %d, %v; want none", count, err)
+	}
+
+	if count, err := m.Notifications.CountUnread(context.Background(), alice.Id); count != 0 || err != nil {
+		t.Fatalf("notifications after rollback = %d, %v; want none", count, err)
+	}
+}
+
+func enqueueJob(t *testing.T, m database.Models, jobType string, runAt time.Time, maxAttempts int) *database.Job {
+	t.Helper()
+
+	job := &database.Job{Type: jobType, Payload: []byte(`{}`), MaxAttempts: maxAttempts, RunAt: runAt}
+	if err := m.Jobs.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("enqueue job: %v", err)
+	}
+	return job
+}
+
+func getJob(t *testing.T, m database.Models, id int) *database.Job {
+	t.Helper()
+
+	job, err := m.Jobs.GetById(context.Background(), id)
+	if err != nil || job == nil {
+		t.Fatalf("GetById(%d) = %+v, %v", id, job, err)
+	}
+	return job
+}
+
+func testJobsClaim(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	now := time.Now()
+
+	first := enqueueJob(t, m, "send", now.Add(-2*time.Second), 3)
+	second := enqueueJob(t, m, "send", now.Add(-time.Second), 3)
+	other := enqueueJob(t, m, "other", now.Add(-3*time.Second), 3)
+	enqueueJob(t, m, "send", now.Add(time.Hour), 3)
+
+	if first.Id == 0 || first.Status != database.JobStatusPending || first.Attempts != 0 || string(first.Payload) != `{}` {
+		t.Fatalf("Enqueue = %+v; want a pending job with an id", first)
+	}
+
+	a, err := m.Jobs.Claim(ctx, "runner-a", []string{"send"}, now, time.Minute, 1)
+	if err != nil || len(a) != 1 || a[0].Id != first.Id || a[0].Attempts != 1 || a[0].LockedBy != "runner-a" || a[0].Status != database.JobStatusRunning {
+		t.Fatalf("Claim(runner-a) = %+v, %v; want the oldest due job on its first attempt", a, err)
+	}
+
+	b, err := m.Jobs.Claim(ctx, "runner-b", []string{"send"}, now, time.Minute, 10)
+	if err != nil || len(b) != 1 || b[0].Id != second.Id || b[0].LockedBy != "runner-b" {
+		t.Fatalf("Claim(runner-b) = %+v, %v; want only the due job runner-a did not take", b, err)
+	}
+
+	if none, err := m.Jobs.Claim(ctx, "runner-b", []string{"send"}, now, time.Minute, 10); len(none) != 0 || err != nil {
+		t.Fatalf("Claim(all leased) = %+v, %v; want none", none, err)
+	}
+
+	if none, err := m.Jobs.Claim(ctx, "runner-b", nil, now, time.Minute, 10); len(none) != 0 || err != nil {
+		t.Fatalf("Claim(no types) = %+v, %v; want none", none, err)
+	}
+
+	if held, err := m.Jobs.Extend(ctx, first.Id, "runner-b", now, time.Minute); held || err != nil {
+		t.Fatalf("Extend(another runner's job) = %v, %v; want false", held, err)
+	}
+
+	if held, err := m.Jobs.Extend(ctx, first.Id, "runner-a", now, time.Minute); !held || err != nil {
+		t.Fatalf("Extend(own job) = %v, %v; want true", held, err)
+	}
+
+	if job := getJob(t, m, other.Id); job.Status != database.JobStatusPending {
+		t.Fatalf("job of an unclaimed type = %+v; want it still pending", job)
+	}
+}
+
+func testJobsLeaseExpiry(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	now := time.Now()
+	job := enqueueJob(t, m, "send", now.Add(-time.Second), 3)
+
+	if claimed, err := m.Jobs.Claim(ctx, "runner-a", []string{"send"}, now, time.Minute, 1); len(claimed) != 1 || err != nil {
+		t.Fatalf("Claim(runner-a) = %+v, %v; want the job", claimed, err)
+	}
+
+	if none, err := m.Jobs.Claim(ctx, "runner-b", []string{"send"}, now.Add(30*time.Second), time.Minute, 1); len(none) != 0 || err != nil {
+		t.Fatalf("Claim(within the lease) = %+v, %v; want none", none, err)
+	}
+
+	// runner-a dies without renewing its lease, so runner-b takes the job over.
+	later := now.Add(2 * time.Minute)
+	taken, err := m.Jobs.Claim(ctx, "runner-b", []string{"send"}, later, time.Minute, 1)
+	if err != nil || len(taken) != 1 || taken[0].Attempts != 2 || taken[0].LockedBy != "runner-b" ||
+		taken[0].LockedUntil == nil || !closeTo(*taken[0].LockedUntil, later.Add(time.Minute)) {
+		t.Fatalf("Claim(after the lease) = %+v, %v; want the job leased to runner-b on its second attempt", taken, err)
+	}
+
+	if held, err := m.Jobs.Extend(ctx, job.Id, "runner-a", later, time.Minute); held || err != nil {
+		t.Fatalf("Extend(lost lease) = %v, %v; want false", held, err)
+	}
+
+	if err := m.Jobs.Complete(ctx, job.Id, "runner-a"); err != nil {
+		t.Fatalf("Complete(lost lease): %v", err)
+	}
+	if got := getJob(t, m, job.Id); got.Status != database.JobStatusRunning || got.LockedBy != "runner-b" {
+		t.Fatalf("job after a complete by the old runner = %+v; want it still running on runner-b", got)
+	}
+
+	if err := m.Jobs.Complete(ctx, job.Id, "runner-b"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	got := getJob(t, m, job.Id)
+	if got.Status != database.JobStatusSucceeded || got.LockedBy != "" || got.LockedUntil != nil || got.FinishedAt == nil {
+		t.Fatalf("completed job = %+v; want it succeeded and unlocked", got)
+	}
+}
+
+func testJobsFailAndRetry(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	now := time.Now()
+	job := enqueueJob(t, m, "send", now.Add(-time.Second), 2)
+
+	if claimed, err := m.Jobs.Claim(ctx, "runner-a", []string{"send"}, now, time.Minute, 1); len(claimed) != 1 || err != nil {
+		t.Fatalf("Claim = %+v, %v; want the job", claimed, err)
+	}
+
+	retryAt := now.Add(time.Hour)
+	if err := m.Jobs.Fail(ctx, job.Id, "runner-a", "timeout", &retryAt); err != nil {
+		t.Fatalf("Fail(retry): %v", err)
+	}
+
+	got := getJob(t, m, job.Id)
+	if got.Status != database.JobStatusPending || got.LastError != "timeout" || !closeTo(got.RunAt, retryAt) || got.LockedBy != "" || got.FinishedAt != nil {
+		t.Fatalf("job after a failed attempt = %+v; want it pending until %v", got, retryAt)
+	}
+
+	if next, err := m.Jobs.NextRunAt(ctx, []string{"send"}); err != nil || next == nil || !closeTo(*next, retryAt) {
+		t.Fatalf("NextRunAt = %v, %v; want %v", next, err, retryAt)
+	}
+
+	if none, err := m.Jobs.Claim(ctx, "runner-a", []string{"send"}, now, time.Minute, 1); len(none) != 0 || err != nil {
+		t.Fatalf("Claim(before the retry) = %+v, %v; want none", none, err)
+	}
+
+	claimed, err := m.Jobs.Claim(ctx, "runner-a", []string{"send"}, retryAt, time.Minute, 1)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Fatalf("Claim(at the retry) = %+v, %v; want the job on its second attempt", claimed, err)
+	}
+
+	if err := m.Jobs.Fail(ctx, job.Id, "runner-a", "still broken", nil); err != nil {
+		t.Fatalf("Fail(give up): %v", err)
+	}
+
+	got = getJob(t, m, job.Id)
+	if got.Status != database.JobStatusFailed || got.LastError != "still broken" || got.FinishedAt == nil {
+		t.Fatalf("job after its last attempt = %+v; want it failed", got)
+	}
+
+	if next, err := m.Jobs.NextRunAt(ctx, []string{"send"}); next != nil || err != nil {
+		t.Fatalf("NextRunAt(none pending) = %v, %v; want nil, nil", next, err)
+	}
+
+	retried, err := m.Jobs.Retry(ctx, job.Id)
+	if err != nil || retried == nil || retried.Status != database.JobStatusPending || retried.Attempts != 0 || retried.FinishedAt != nil {
+		t.Fatalf("Retry = %+v, %v; want the job pending with its attempts reset", retried, err)
+	}
+
+	if again, err := m.Jobs.Retry(ctx, job.Id); again != nil || err != nil {
+		t.Fatalf("Retry(pending job) = %+v, %v; want nil, nil", again, err)
+	}
+}
@@ -0,0 +1,623 @@
+// Package storetest checks that an implementation of the database store interfaces
+// behaves like the SQLite models. Every implementation should pass Run.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-event-crud/internal/database"
+	"testing"
+	"time"
+)
+
+// Run runs the conformance suite. newModels must return Models whose stores are empty and
+// not shared with any other call.
+func Run(t *testing.T, newModels func(t *testing.T) database.Models) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, m database.Models)
+	}{
+		{"Users/InsertAndGet", testUsersInsertAndGet},
+		{"Users/DuplicateEmail", testUsersDuplicateEmail},
+		{"Users/UpdatePassword", testUsersUpdatePassword},
+		{"Events/InsertDefaults", testEventsInsertDefaults},
+		{"Events/DraftVisibility", testEventsDraftVisibility},
+		{"Events/UpdateVersion", testEventsUpdateVersion},
+		{"Events/UpdateFields", testEventsUpdateFields},
+		{"Events/Transition", testEventsTransition},
+		{"Events/ScheduleAndPublish", testEventsScheduleAndPublish},
+		{"Events/Trash", testEventsTrash},
+		{"Events/GetByAttendee", testEventsGetByAttendee},
+		{"Attendees/InsertAndDelete", testAttendeesInsertAndDelete},
+		{"Attendees/ApplyChangesAtomic", testAttendeesApplyChangesAtomic},
+		{"Attendees/ApplyChangesBestEffort", testAttendeesApplyChangesBestEffort},
+		{"EventRevisions/Numbering", testRevisionsNumbering},
+		{"Notifications/Inbox", testNotificationsInbox},
+		{"IdempotencyKeys/Lifecycle", testIdempotencyKeysLifecycle},
+		{"Emails/Queue", testEmailsQueue},
+		{"EmailPreferences/Defaults", testEmailPreferencesDefaults},
+		{"PasswordResets/Consume", testPasswordResetsConsume},
+		{"Reminders/UpcomingAndClaim", testRemindersUpcomingAndClaim},
+		{"Reminders/Rescheduled", testRemindersRescheduled},
+		{"Jobs/Claim", testJobsClaim},
+		{"Jobs/LeaseExpiry", testJobsLeaseExpiry},
+		{"Jobs/FailAndRetry", testJobsFailAndRetry},
+		{"Transact/Commit", testTransactCommit},
+		{"Transact/Rollback", testTransactRollback},
+		{"Transact/RollbackNotifications", testTransactRollbackNotifications},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newModels(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, m database.Models, name string) *database.User {
+	t.Helper()
+
+	user := &database.User{Email: name + "@example.com", Name: name, Password: "hash"}
+	if err := m.Users.Insert(context.Background(), user); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return user
+}
+
+func createEvent(t *testing.T, m database.Models, ownerId int, status string) *database.Event {
+	t.Helper()
+
+	event := &database.Event{
+		OwnerId:     ownerId,
+		Name:        "Conference",
+		Description: "A conference about Go",
+		Date:        "2030-05-01",
+		Location:    "Berlin",
+		Status:      status,
+	}
+	if err := m.Events.Insert(context.Background(), event); err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+	return event
+}
+
+func getEvent(t *testing.T, m database.Models, id int) *database.Event {
+	t.Helper()
+
+	event, err := m.Events.GetById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get event %d: %v", id, err)
+	}
+	return event
+}
+
+// day returns the date part of an event date, which stores may return with a time attached.
+func day(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
+
+func eventIds(events []*database.Event) []int {
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+	return ids
+}
+
+func same(got, want any) bool {
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
+
+func testUsersInsertAndGet(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+	bob := createUser(t, m, "bob")
+
+	if alice.Id == 0 || alice.Id == bob.Id {
+		t.Fatalf("got ids %d and %d, want distinct non-zero ids", alice.Id, bob.Id)
+	}
+
+	byId, err := m.Users.GetById(ctx, alice.Id)
+	if err != nil || byId == nil || byId.Email != alice.Email || byId.Password != alice.Password {
+		t.Fatalf("GetById = %+v, %v; want %+v", byId, err, alice)
+	}
+
+	byEmail, err := m.Users.GetByEmail(ctx, bob.Email)
+	if err != nil || byEmail == nil || byEmail.Id != bob.Id {
+		t.Fatalf("GetByEmail = %+v, %v; want %+v", byEmail, err, bob)
+	}
+
+	if missing, err := m.Users.GetById(ctx, 999); missing != nil || err != nil {
+		t.Fatalf("GetById(missing) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	if missing, err := m.Users.GetByEmail(ctx, "nobody@example.com"); missing != nil || err != nil {
+		t.Fatalf("GetByEmail(missing) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testUsersDuplicateEmail(t *testing.T, m database.Models) {
+	createUser(t, m, "alice")
+
+	err := m.Users.Insert(context.Background(), &database.User{Email: "alice@example.com", Name: "Other", Password: "hash"})
+	if err == nil {
+		t.Fatal("inserting a duplicate email succeeded, want an error")
+	}
+}
+
+func testUsersUpdatePassword(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	alice := createUser(t, m, "alice")
+
+	if err := m.Users.UpdatePassword(ctx, alice.Id, "new hash"); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+
+	user, err := m.Users.GetById(ctx, alice.Id)
+	if err != nil || user == nil || user.Password != "new hash" {
+		t.Fatalf("GetById = %+v, %v; want password %q", user, err, "new hash")
+	}
+}
+
+func testEventsInsertDefaults(t *testing.T, m database.Models) {
+	owner := createUser(t, m, "owner")
+	event := createEvent(t, m, owner.Id, "")
+
+	if event.Id == 0 || event.Version != 1 || event.Status != database.EventStatusPublished {
+		t.Fatalf("inserted event = %+v, want an id, version 1 and status published", event)
+	}
+
+	stored := getEvent(t, m, event.Id)
+	if stored == nil {
+		t.Fatal("GetById returned nil for an inserted event")
+	}
+
+	if stored.Name != event.Name || stored.OwnerId != owner.Id || day(stored.Date) != event.Date || stored.Version != 1 {
+		t.Fatalf("stored event = %+v, want %+v", stored, event)
+	}
+
+	if missing := getEvent(t, m, 999); missing != nil {
+		t.Fatalf("GetById(missing) = %+v, want nil", missing)
+	}
+}
+
+func testEventsDraftVisibility(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	other := createUser(t, m, "other")
+
+	published := createEvent(t, m, owner.Id, database.EventStatusPublished)
+	draft := createEvent(t, m, owner.Id, database.EventStatusDraft)
+
+	ownerView, err := m.Events.GetAll(ctx, owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eventIds(ownerView), []int{published.Id, draft.Id}; !same(got, want) {
+		t.Fatalf("owner sees %v, want %v", got, want)
+	}
+
+	otherView, err := m.Events.GetAll(ctx, other.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eventIds(otherView), []int{published.Id}; !same(got, want) {
+		t.Fatalf("other user sees %v, want %v", got, want)
+	}
+
+	anonymousView, err := m.Events.GetAll(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eventIds(anonymousView), []int{published.Id}; !same(got, want) {
+		t.Fatalf("anonymous caller sees %v, want %v", got, want)
+	}
+}
+
+func testEventsUpdateVersion(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	event := createEvent(t, m, owner.Id, "")
+
+	stale := *event
+
+	event.Name = "Renamed conference"
+	event.Date = "2030-06-01"
+	if err := m.Events.Update(ctx, event); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if event.Version != 2 {
+		t.Fatalf("version after update = %d, want 2", event.Version)
+	}
+
+	stored := getEvent(t, m, event.Id)
+	if stored.Name != "Renamed conference" || day(stored.Date) != "2030-06-01" || stored.Version != 2 {
+		t.Fatalf("stored event = %+v, want the update applied at version 2", stored)
+	}
+
+	stale.Name = "Lost update"
+	if err := m.Events.Update(ctx, &stale); !errors.Is(err, database.ErrEditConflict) {
+		t.Fatalf("Update with a stale version = %v, want ErrEditConflict", err)
+	}
+}
+
+func testEventsUpdateFields(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	event := createEvent(t, m, owner.Id, "")
+
+	if err := m.Events.UpdateFields(ctx, event, map[string]any{"owner_id": 2}); err == nil {
+		t.Fatal("UpdateFields accepted a column that is not updatable")
+	}
+
+	if err := m.Events.UpdateFields(ctx, event, map[string]any{"location": "Paris"}); err != nil {
+		t.Fatalf("UpdateFields: %v", err)
+	}
+
+	stored := getEvent(t, m, event.Id)
+	if stored.Location != "Paris" || stored.Name != event.Name || stored.Version != 2 || event.Version != 2 {
+		t.Fatalf("stored event = %+v, want only the location changed at version 2", stored)
+	}
+
+	stale := *event
+	stale.Version = 1
+	if err := m.Events.UpdateFields(ctx, &stale, map[string]any{"name": "Lost update"}); !errors.Is(err, database.ErrEditConflict) {
+		t.Fatalf("UpdateFields with a stale version = %v, want ErrEditConflict", err)
+	}
+}
+
+func testEventsTransition(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	event := createEvent(t, m, owner.Id, "")
+
+	stale := *event
+
+	ok, err := m.Events.Transition(ctx, event, database.EventStatusPostponed, "Venue unavailable", "2030-07-01")
+	if err != nil || !ok {
+		t.Fatalf("Transition = %v, %v; want true, nil", ok, err)
+	}
+
+	stored := getEvent(t, m, event.Id)
+	if stored.Status != database.EventStatusPostponed || stored.StatusReason != "Venue unavailable" ||
+		day(stored.Date) != "2030-07-01" || stored.StatusChangedAt == nil || stored.Version != 2 {
+		t.Fatalf("stored event = %+v, want it postponed to 2030-07-01 at version 2", stored)
+	}
+
+	ok, err = m.Events.Transition(ctx, &stale, database.EventStatusCancelled, "Stale", "")
+	if err != nil || ok {
+		t.Fatalf("Transition from a stale status = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func testEventsScheduleAndPublish(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	published := createEvent(t, m, owner.Id, database.EventStatusPublished)
+	soon := createEvent(t, m, owner.Id, database.EventStatusDraft)
+	later := createEvent(t, m, owner.Id, database.EventStatusDraft)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	soonAt, laterAt := now.Add(-time.Minute), now.Add(time.Hour)
+
+	if ok, err := m.Events.Schedule(ctx, published, &soonAt); err != nil || ok {
+		t.Fatalf("Schedule(published event) = %v, %v; want false, nil", ok, err)
+	}
+	if ok, err := m.Events.Schedule(ctx, soon, &soonAt); err != nil || !ok {
+		t.Fatalf("Schedule(draft) = %v, %v; want true, nil", ok, err)
+	}
+	if ok, err := m.Events.Schedule(ctx, later, &laterAt); err != nil || !ok {
+		t.Fatalf("Schedule(draft) = %v, %v; want true, nil", ok, err)
+	}
+
+	next, err := m.Events.NextPublishAt(ctx)
+	if err != nil || next == nil || !next.Equal(soonAt) {
+		t.Fatalf("NextPublishAt = %v, %v; want %v", next, err, soonAt)
+	}
+
+	ids, err := m.Events.PublishDue(ctx, now)
+	if err != nil || !same(ids, []int{soon.Id}) {
+		t.Fatalf("PublishDue = %v, %v; want [%d]", ids, err, soon.Id)
+	}
+
+	stored := getEvent(t, m, soon.Id)
+	if stored.Status != database.EventStatusPublished || stored.PublishAt != nil {
+		t.Fatalf("published event = %+v, want it published with no schedule", stored)
+	}
+
+	next, err = m.Events.NextPublishAt(ctx)
+	if err != nil || next == nil || !next.Equal(laterAt) {
+		t.Fatalf("NextPublishAt = %v, %v; want %v", next, err, laterAt)
+	}
+
+	if ok, err := m.Events.Schedule(ctx, later, nil); err != nil || !ok {
+		t.Fatalf("Schedule(nil) = %v, %v; want true, nil", ok, err)
+	}
+
+	next, err = m.Events.NextPublishAt(ctx)
+	if err != nil || next != nil {
+		t.Fatalf("NextPublishAt with nothing scheduled = %v, %v; want nil, nil", next, err)
+	}
+}
+
+func testEventsTrash(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	attendee := createUser(t, m, "attendee")
+	kept := createEvent(t, m, owner.Id, "")
+	restored := createEvent(t, m, owner.Id, "")
+	purged := createEvent(t, m, owner.Id, "")
+
+	if _, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: purged.Id, UserId: attendee.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Events.Delete(ctx, restored.Id, restored.Version+1); !errors.Is(err, database.ErrEditConflict) {
+		t.Fatalf("Delete with a stale version = %v, want ErrEditConflict", err)
+	}
+
+	for _, event := range []*database.Event{restored, purged} {
+		if err := m.Events.Delete(ctx, event.Id, event.Version); err != nil {
+			t.Fatalf("Delete(%d): %v", event.Id, err)
+		}
+	}
+
+	if event := getEvent(t, m, purged.Id); event != nil {
+		t.Fatalf("GetById returned deleted event %+v", event)
+	}
+
+	trashed, err := m.Events.GetDeletedById(ctx, purged.Id)
+	if err != nil || trashed == nil || trashed.DeletedAt == nil || trashed.Version != 2 {
+		t.Fatalf("GetDeletedById = %+v, %v; want the event at version 2 with a deletion time", trashed, err)
+	}
+
+	if event, err := m.Events.GetDeletedById(ctx, kept.Id); event != nil || err != nil {
+		t.Fatalf("GetDeletedById(live event) = %+v, %v; want nil, nil", event, err)
+	}
+
+	trash, err := m.Events.GetDeletedByOwner(ctx, owner.Id)
+	if err != nil || len(trash) != 2 {
+		t.Fatalf("GetDeletedByOwner = %v, %v; want 2 events", eventIds(trash), err)
+	}
+
+	if err := m.Events.Restore(ctx, restored.Id); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	event := getEvent(t, m, restored.Id)
+	if event == nil || event.DeletedAt != nil {
+		t.Fatalf("restored event = %+v, want it back out of the trash", event)
+	}
+
+	if err := m.Events.Restore(ctx, restored.Id); !errors.Is(err, database.ErrRecordNotFound) {
+		t.Fatalf("Restore(live event) = %v, want ErrRecordNotFound", err)
+	}
+	if again := getEvent(t, m, restored.Id); again.Version != event.Version {
+		t.Fatalf("version after restoring a live event = %d, want it unchanged at %d", again.Version, event.Version)
+	}
+
+	if err := m.Events.Restore(ctx, 9999); !errors.Is(err, database.ErrRecordNotFound) {
+		t.Fatalf("Restore(missing event) = %v, want ErrRecordNotFound", err)
+	}
+
+	count, err := m.Events.Purge(ctx, time.Now().Add(time.Minute))
+	if err != nil || count != 1 {
+		t.Fatalf("Purge = %d, %v; want 1, nil", count, err)
+	}
+
+	if event, err := m.Events.GetDeletedById(ctx, purged.Id); event != nil || err != nil {
+		t.Fatalf("GetDeletedById(purged event) = %+v, %v; want nil, nil", event, err)
+	}
+
+	if a, err := m.Attendees.GetByEventAndAttendee(ctx, purged.Id, attendee.Id); a != nil || err != nil {
+		t.Fatalf("attendee of purged event = %+v, %v; want it removed", a, err)
+	}
+}
+
+func testEventsGetByAttendee(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	attendee := createUser(t, m, "attendee")
+	published := createEvent(t, m, owner.Id, database.EventStatusPublished)
+	draft := createEvent(t, m, owner.Id, database.EventStatusDraft)
+	deleted := createEvent(t, m, owner.Id, database.EventStatusPublished)
+
+	for _, event := range []*database.Event{published, draft, deleted} {
+		if _, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: event.Id, UserId: attendee.Id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.Events.Delete(ctx, deleted.Id, deleted.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := m.Events.GetByAttendee(ctx, attendee.Id)
+	if err != nil || len(events) != 1 || events[0].Id != published.Id {
+		t.Fatalf("GetByAttendee = %+v, %v; want only event %d", events, err, published.Id)
+	}
+}
+
+func testAttendeesInsertAndDelete(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	attendee := createUser(t, m, "attendee")
+	event := createEvent(t, m, owner.Id, "")
+
+	inserted, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: event.Id, UserId: attendee.Id})
+	if err != nil || inserted.Id == 0 {
+		t.Fatalf("Insert = %+v, %v; want an attendee with an id", inserted, err)
+	}
+
+	found, err := m.Attendees.GetByEventAndAttendee(ctx, event.Id, attendee.Id)
+	if err != nil || found == nil || found.Id != inserted.Id {
+		t.Fatalf("GetByEventAndAttendee = %+v, %v; want %+v", found, err, inserted)
+	}
+
+	users, err := m.Attendees.GetAttendeesByEvent(ctx, event.Id)
+	if err != nil || len(users) != 1 || users[0].Id != attendee.Id || users[0].Email != attendee.Email {
+		t.Fatalf("GetAttendeesByEvent = %+v, %v; want [%+v]", users, err, attendee)
+	}
+
+	if err := m.Attendees.Delete(ctx, attendee.Id, event.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if found, err := m.Attendees.GetByEventAndAttendee(ctx, event.Id, attendee.Id); found != nil || err != nil {
+		t.Fatalf("GetByEventAndAttendee after delete = %+v, %v; want nil, nil", found, err)
+	}
+}
+
+func testAttendeesApplyChangesAtomic(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	event := createEvent(t, m, owner.Id, "")
+
+	changes := []database.AttendeeChange{
+		{Action: database.AttendeeActionAdd, UserId: alice.Id},
+		{Action: database.AttendeeActionAdd, Email: "nobody@example.com"},
+	}
+
+	results, applied, err := m.Attendees.ApplyChanges(ctx, event.Id, changes, true)
+	if err != nil || applied {
+		t.Fatalf("ApplyChanges = %v, %v; want it not applied", applied, err)
+	}
+
+	want := []string{database.AttendeeStatusRolledBack, database.AttendeeStatusUserNotFound}
+	if got := statuses(results); !same(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+
+	if found, err := m.Attendees.GetByEventAndAttendee(ctx, event.Id, alice.Id); found != nil || err != nil {
+		t.Fatalf("rolled back attendee = %+v, %v; want nil, nil", found, err)
+	}
+}
+
+func testAttendeesApplyChangesBestEffort(t *testing.T, m database.Models) {
+	ctx := context.Background()
+	owner := createUser(t, m, "owner")
+	alice := createUser(t, m, "alice")
+	bob := createUser(t, m, "bob")
+	carol := createUser(t, m, "carol")
+	event := createEvent(t, m, owner.Id, "")
+
+	if _, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: event.Id, UserId: bob.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []database.AttendeeChange{
+		{Action: database.AttendeeActionAdd, Email: alice.Email},
+		{Action: database.AttendeeActionAdd, UserId: bob.Id},
+		{Action: database.AttendeeActionRemove, UserId: bob.Id},
+		{Action: database.AttendeeActionRemove, UserId: carol.Id},
+		{Action: database.AttendeeActionAdd, UserId: 999},
+	}
+
+	results, applied, err := m.Attendees.ApplyChanges(ctx, event.Id, changes, false)
+	if err != nil || !applied {
+		t.Fatalf("ApplyChanges = %v, %v; want it applied", applied, err)
+	}
+
+	want := []string{
+		database.AttendeeStatusAdded,
+		database.AttendeeStatusAlreadyAttending,
+		database.AttendeeStatusRemoved,
+		database.AttendeeStatusNotAttending,
+		database.AttendeeStatusUserNotFound,
+	}
+	if got := statuses(results); !same(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+
+	if results[0].UserId != alice.Id {
+		t.Fatalf("change by email resolved to user %d, want %d", results[0].UserId, alice.Id)
+	}
+	if results[1].Email != "" {
+		t.Fatalf("change by ID returned email %q, want none", results[1].Email)
+	}
+
+	users, err := m.Attendees.GetAttendeesByEvent(ctx, event.Id)
+	if err != nil || len(users) != 1 || users[0].Id != alice.Id {
+		t.Fatalf("attendees after batch = %+v, %v; want only alice", users, err)
+	}
+}
+
+func statuses(results []database.AttendeeChangeResult) []string {
+	s := make([]string, 0, len(results))
+	for _, result := range results {
+		s = append(s, result.Status)
+	}
+	return s
+}
+
+func testTransactCommit(t *testing.T, m database.Models) {
+	owner := createUser(t, m, "owner")
+
+	var event *database.Event
+	err := m.Transact(context.Background(), func(ctx context.Context) error {
+		event = &database.Event{OwnerId: owner.Id, Name: "Meetup", Description: "A meetup about Go", Date: "2030-05-01", Location: "Lyon"}
+		if err := m.Events.Insert(ctx, event); err != nil {
+			return err
+		}
+
+		_, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: event.Id, UserId: owner.Id})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+
+	if stored := getEvent(t, m, event.Id); stored == nil {
+		t.Fatal("event inserted in a committed transaction is missing")
+	}
+
+	if a, err := m.Attendees.GetByEventAndAttendee(context.Background(), event.Id, owner.Id); a == nil || err != nil {
+		t.Fatalf("attendee inserted in a committed transaction = %+v, %v; want it stored", a, err)
+	}
+}
+
+func testTransactRollback(t *testing.T, m database.Models) {
+	owner := createUser(t, m, "owner")
+	event := createEvent(t, m, owner.Id, "")
+	errAbort := errors.New("abort")
+
+	err := m.Transact(context.Background(), func(ctx context.Context) error {
+		event.Name = "Renamed conference"
+		if err := m.Events.Update(ctx, event); err != nil {
+			return err
+		}
+
+		guest := &database.User{Email: "guest@example.com", Name: "guest", Password: "hash"}
+		if err := m.Users.Insert(ctx, guest); err != nil {
+			return err
+		}
+
+		// A nested unit of work joins the outer one and is rolled back with it.
+		return m.Transact(ctx, func(ctx context.Context) error {
+			if _, err := m.Attendees.Insert(ctx, &database.Attendee{EventId: event.Id, UserId: guest.Id}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transact = %v, want the error returned by fn", err)
+	}
+
+	if stored := getEvent(t, m, event.Id); stored.Name != "Conference" || stored.Version != 1 {
+		t.Fatalf("event after rollback = %+v, want it unchanged", stored)
+	}
+
+	if guest, err := m.Users.GetByEmail(context.Background(), "guest@example.com"); guest != nil || err != nil {
+		t.Fatalf("user after rollback = %+v, %v; want nil, nil", guest, err)
+	}
+
+	users, err := m.Attendees.GetAttendeesByEvent(context.Background(), event.Id)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("attendees after rollback = %+v, %v; want none", users, err)
+	}
+}
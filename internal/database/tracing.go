@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer is a QueryHook that wraps each model method in a span.
+type queryTracer struct {
+	tracer trace.Tracer
+	system attribute.KeyValue
+}
+
+// NewQueryTracer returns a QueryHook that records a span named after the model method,
+// such as "EventModel.GetById", for every model method, as a child of the span in the
+// method's context.
+func NewQueryTracer(provider trace.TracerProvider, dialect Dialect) QueryHook {
+	system := semconv.DBSystemSqlite
+	if dialect == Postgres {
+		system = semconv.DBSystemPostgreSQL
+	}
+
+	return queryTracer{
+		tracer: provider.Tracer("go-event-crud/internal/database"),
+		system: system,
+	}
+}
+
+func (t queryTracer) BeforeQuery(ctx context.Context, op string) context.Context {
+	ctx, _ = t.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.system, semconv.DBOperationName(op)),
+	)
+	return ctx
+}
+
+func (t queryTracer) AfterQuery(ctx context.Context, op string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	maxTxAttempts  = 5
+	txRetryBackoff = 10 * time.Millisecond
+)
+
+// Transactor runs a function as a single unit of work.
+type Transactor interface {
+	// Transact runs fn in a transaction and commits it if fn returns nil. Every model
+	// method called with the context passed to fn takes part in the transaction. fn may be
+	// run more than once if the transaction has to be retried, so it must not have side
+	// effects outside the database. Calling Transact with a context that is already in a
+	// transaction runs fn in that transaction.
+	Transact(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// querier is the part of *sql.DB and *sql.Tx the models use to run queries.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// conn returns the transaction ctx is running in, or db if there is none.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// inTx runs fn in the transaction ctx is running in, or in a new one that is committed
+// when fn succeeds. It lets methods that need a transaction join a caller's unit of work.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlTransactor runs units of work on a SQL database. SQLite transactions begin in
+// immediate mode (see Open) and PostgreSQL transactions are serializable; both are retried
+// when they lose a race with another writer.
+type sqlTransactor struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func (t sqlTransactor) Transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	var opts *sql.TxOptions
+	if t.dialect == Postgres {
+		opts = &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+
+	backoff := txRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := t.transactOnce(ctx, opts, fn)
+		if err == nil || attempt == maxTxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		// Jitter keeps writers that collided from retrying in lockstep.
+		wait := backoff/2 + rand.N(backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func (t sqlTransactor) transactOnce(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := t.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isRetryable reports whether a transaction failed only because of a concurrent writer:
+// a busy or locked SQLite database, or a PostgreSQL serialization failure or deadlock.
+func isRetryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	return false
+}
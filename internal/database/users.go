@@ -3,11 +3,11 @@ package database
 import (
 	"context"
 	"database/sql"
-	"time"
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	opts *QueryOptions
 }
 
 type User struct {
@@ -17,24 +17,24 @@ type User struct {
 	Password string `json:"-"`
 }
 
-func (m *UserModel) Insert(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+func (m UserModel) Insert(ctx context.Context, user *User) (err error) {
+	ctx, done := m.opts.begin(ctx, "UserModel.Insert")
+	defer done(&err)
 
 	stmt := `INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id`
-	err := m.DB.QueryRowContext(ctx, stmt, user.Email, user.Password, user.Name).Scan(&user.Id)
+	err = conn(ctx, m.DB).QueryRowContext(ctx, stmt, user.Email, user.Password, user.Name).Scan(&user.Id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *UserModel) getUser(query string, args ...any) (*User, error) {
-    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-    defer cancel()
+func (m UserModel) getUser(ctx context.Context, op string, query string, args ...any) (_ *User, err error) {
+    ctx, done := m.opts.begin(ctx, op)
+    defer done(&err)
 
     var user User
-    err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Id, &user.Email, &user.Name, &user.Password)
+    err = conn(ctx, m.DB).QueryRowContext(ctx, query, args...).Scan(&user.Id, &user.Email, &user.Name, &user.Password)
     if err != nil {
         if err == sql.ErrNoRows {
             return nil, nil
@@ -44,12 +44,20 @@ func (m *UserModel) getUser(query string, args ...any) (*User, error) {
     return &user, nil
 }
 
-func (m *UserModel) GetById(id int) (*User, error) {
-    query := `SELECT * FROM users WHERE id = $1`
-    return m.getUser(query, id)
+func (m UserModel) GetById(ctx context.Context, id int) (*User, error) {
+    query := `SELECT id, email, name, password FROM users WHERE id = $1`
+    return m.getUser(ctx, "UserModel.GetById", query, id)
 }
 
-func (m *UserModel) GetByEmail(email string) (*User, error) {
-    query := `SELECT * FROM users WHERE email = $1`
-    return m.getUser(query, email)
+func (m UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+    query := `SELECT id, email, name, password FROM users WHERE email = $1`
+    return m.getUser(ctx, "UserModel.GetByEmail", query, email)
+}
+
+func (m UserModel) UpdatePassword(ctx context.Context, id int, hash string) (err error) {
+    ctx, done := m.opts.begin(ctx, "UserModel.UpdatePassword")
+    defer done(&err)
+
+    _, err = conn(ctx, m.DB).ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2`, hash, id)
+    return err
 }
\ No newline at end of file
@@ -0,0 +1,127 @@
+// Package jobs runs background work from a queue kept in the database, so that work
+// survives restarts and is shared by every instance of the API.
+//
+// Each kind of job is a Type with a payload type of its own. A handler is registered for
+// it with Handle, and jobs of it are queued with Type.Enqueue:
+//
+//	var sendWebhook = jobs.NewType[webhook]("send_webhook")
+//
+//	jobs.Handle(queue, sendWebhook, func(ctx context.Context, w webhook) error { ... })
+//	sendWebhook.Enqueue(ctx, queue, webhook{URL: url}, jobs.Delay(time.Minute))
+//
+// A job runs at least once: a failed job is retried with exponential backoff until it has
+// used up its attempts, and a job whose runner dies is run again once its lease runs out.
+// Handlers should therefore be safe to run more than once.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-event-crud/internal/database"
+)
+
+// Type is a kind of job whose payload is a T, marshalled as JSON.
+type Type[T any] struct {
+	name string
+}
+
+// NewType returns the job type with the given name. The name is stored with every job,
+// so it must not change while jobs of the type may be queued.
+func NewType[T any](name string) Type[T] {
+	return Type[T]{name: name}
+}
+
+// Name returns the name of the type.
+func (t Type[T]) Name() string {
+	return t.name
+}
+
+// Enqueue queues a job of the type. Called inside a transaction, the job is only queued if
+// the transaction commits.
+func (t Type[T]) Enqueue(ctx context.Context, q *Queue, payload T, opts ...Option) (*database.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: marshal %s payload: %w", t.name, err)
+	}
+
+	job := &database.Job{
+		Type:        t.name,
+		Payload:     data,
+		MaxAttempts: q.config.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if err := q.store.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	q.Wake()
+	return job, nil
+}
+
+// Option changes how a job is queued.
+type Option func(*database.Job)
+
+// Delay runs a job no earlier than d from now.
+func Delay(d time.Duration) Option {
+	return func(job *database.Job) {
+		job.RunAt = time.Now().Add(d)
+	}
+}
+
+// At runs a job no earlier than t.
+func At(t time.Time) Option {
+	return func(job *database.Job) {
+		job.RunAt = t
+	}
+}
+
+// MaxAttempts sets how many times a job is tried before it fails for good.
+func MaxAttempts(n int) Option {
+	return func(job *database.Job) {
+		job.MaxAttempts = n
+	}
+}
+
+// handler runs a job from its JSON payload.
+type handler func(ctx context.Context, payload string) error
+
+// Handle registers fn as the handler of a job type, replacing any handler it had. Only
+// jobs of types with a handler are claimed, so instances running different versions do not
+// take jobs they cannot run. Handlers must be registered before Run is called.
+func Handle[T any](q *Queue, t Type[T], fn func(ctx context.Context, payload T) error) {
+	q.handlers[t.name] = func(ctx context.Context, data string) error {
+		var payload T
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			return Permanent(fmt.Errorf("unmarshal payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// permanentError marks an error that retrying will not fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error returned by a handler so that the job fails at once instead
+// of being retried.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go-event-crud/internal/database"
+	"go-event-crud/internal/logging"
+)
+
+const (
+	// retryBase is the wait after a job's first failed attempt. It doubles with every
+	// further attempt, up to retryMax.
+	retryBase = 10 * time.Second
+	retryMax  = time.Hour
+)
+
+// Config sets how a Queue runs jobs.
+type Config struct {
+	// Concurrency is how many jobs an instance runs at once.
+	Concurrency int
+	// Lease is how long a runner holds a job before another runner may take it over. A
+	// running job's lease is renewed until it finishes, so it only runs out when the
+	// runner dies.
+	Lease time.Duration
+	// MaxAttempts is how many times a job is tried, unless it was queued with MaxAttempts.
+	MaxAttempts int
+}
+
+// Queue queues jobs and runs them with the registered handlers.
+type Queue struct {
+	config   Config
+	store    database.JobStore
+	runner   string
+	handlers map[string]handler
+	wake     chan struct{}
+
+	// OnFinish, if set, is called after every attempt at a job with its outcome:
+	// "succeeded", "retry" or "failed".
+	OnFinish func(job *database.Job, result string)
+}
+
+// New returns a queue that keeps its jobs in store.
+func New(store database.JobStore, config Config) *Queue {
+	return &Queue{
+		config:   config,
+		store:    store,
+		runner:   runnerId(),
+		handlers: make(map[string]handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// runnerId names this process in the leases it takes, so an operator can tell which
+// instance holds a job.
+func runnerId() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Wake makes Run look for due jobs, so a newly queued job starts without waiting for the
+// next poll.
+func (q *Queue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs jobs until ctx is done, then waits for the running ones to return. It sleeps
+// until the next job is due, but never longer than interval, so jobs queued by other
+// instances and jobs whose runner died are picked up too.
+//
+// Jobs stopped by ctx are not recorded as failed; their lease runs out and they run
+// again, here after a restart or on another instance.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	types := slices.Sorted(maps.Keys(q.handlers))
+	slots := make(chan struct{}, q.config.Concurrency)
+
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	for {
+		wait := interval
+
+		jobs, err := q.store.Claim(ctx, q.runner, types, time.Now(), q.config.Lease, cap(slots)-len(slots))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to claim jobs", "error", err)
+		} else {
+			for _, job := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer func() {
+						<-slots
+						running.Done()
+						q.Wake()
+					}()
+					q.run(ctx, job)
+				}()
+			}
+
+			next, err := q.store.NextRunAt(ctx, types)
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to read job queue", "error", err)
+			} else if next != nil && time.Until(*next) < wait {
+				wait = max(time.Until(*next), 0)
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-q.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// run runs a claimed job, renewing its lease while the handler runs, and records the
+// outcome.
+func (q *Queue) run(ctx context.Context, job *database.Job) {
+	logger := logging.FromContext(ctx).With("job_id", job.Id, "job_type", job.Type, "attempt", job.Attempts)
+
+	jobCtx, cancel := context.WithCancel(logging.NewContext(ctx, logger))
+	defer cancel()
+
+	go q.renewLease(jobCtx, cancel, job, logger)
+
+	start := time.Now()
+	err := q.handle(jobCtx, job)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		if err := q.store.Complete(ctx, job.Id, q.runner); err != nil {
+			logger.Error("failed to record job success", "error", err)
+		}
+		logger.Info("job succeeded", "duration_ms", float64(time.Since(start).Microseconds())/1000)
+		q.finished(job, "succeeded")
+		return
+	}
+
+	var retryAt *time.Time
+	if !IsPermanent(err) && job.Attempts < job.MaxAttempts {
+		next := time.Now().Add(backoff(job.Attempts))
+		retryAt = &next
+	}
+
+	if err := q.store.Fail(ctx, job.Id, q.runner, err.Error(), retryAt); err != nil {
+		logger.Error("failed to record job failure", "error", err)
+	}
+
+	if retryAt == nil {
+		logger.Error("job failed, giving up", "error", err)
+		q.finished(job, "failed")
+		return
+	}
+	logger.Warn("job failed, will retry", "error", err, "retry_at", *retryAt)
+	q.finished(job, "retry")
+}
+
+// handle runs a job's handler, turning a panic into an error.
+func (q *Queue) handle(ctx context.Context, job *database.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	h, ok := q.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	}
+	return h(ctx, string(job.Payload))
+}
+
+// renewLease extends the lease on a job every third of the lease until ctx is done. If the
+// lease has been lost, it cancels the job, since another runner may have taken it over.
+func (q *Queue) renewLease(ctx context.Context, cancel context.CancelFunc, job *database.Job, logger *slog.Logger) {
+	ticker := time.NewTicker(q.config.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		held, err := q.store.Extend(ctx, job.Id, q.runner, time.Now(), q.config.Lease)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("failed to renew job lease", "error", err)
+			continue
+		}
+		if !held {
+			logger.Error("lost job lease, stopping job")
+			cancel()
+			return
+		}
+	}
+}
+
+func (q *Queue) finished(job *database.Job, result string) {
+	if q.OnFinish != nil {
+		q.OnFinish(job, result)
+	}
+}
+
+// backoff returns how long to wait before retrying a job that has failed attempts times.
+func backoff(attempts int) time.Duration {
+	return min(retryBase<<min(max(attempts-1, 0), 16), retryMax)
+}
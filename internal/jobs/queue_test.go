@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-event-crud/internal/database"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{1000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+type payload struct {
+	N int `json:"n"`
+}
+
+var testJob = NewType[payload]("test")
+
+// runQueue runs q until want jobs have finished, and returns their outcomes in order.
+func runQueue(t *testing.T, q *Queue, want int) []string {
+	t.Helper()
+
+	results := make(chan string, want)
+	q.OnFinish = func(_ *database.Job, result string) { results <- result }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		q.Run(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < want {
+		select {
+		case result := <-results:
+			got = append(got, result)
+		case <-timeout:
+			t.Fatalf("got outcomes %v before timing out, want %d", got, want)
+		}
+	}
+	return got
+}
+
+func TestQueueRunsHandler(t *testing.T) {
+	models := database.NewMemoryModels()
+	q := New(models.Jobs, Config{Concurrency: 2, Lease: time.Minute, MaxAttempts: 3})
+
+	ran := make(chan int, 1)
+	Handle(q, testJob, func(_ context.Context, p payload) error {
+		ran <- p.N
+		return nil
+	})
+
+	job, err := testJob.Enqueue(context.Background(), q, payload{N: 7})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if got := runQueue(t, q, 1); got[0] != "succeeded" {
+		t.Fatalf("outcome = %s, want succeeded", got[0])
+	}
+	if n := <-ran; n != 7 {
+		t.Fatalf("handler got payload %d, want 7", n)
+	}
+
+	stored, err := models.Jobs.GetById(context.Background(), job.Id)
+	if err != nil || stored.Status != database.JobStatusSucceeded {
+		t.Fatalf("job = %+v, %v; want it succeeded", stored, err)
+	}
+}
+
+func TestQueueRetriesFailedJob(t *testing.T) {
+	models := database.NewMemoryModels()
+	q := New(models.Jobs, Config{Concurrency: 1, Lease: time.Minute, MaxAttempts: 3})
+
+	Handle(q, testJob, func(context.Context, payload) error {
+		return errors.New("unavailable")
+	})
+
+	start := time.Now()
+	job, err := testJob.Enqueue(context.Background(), q, payload{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if got := runQueue(t, q, 1); got[0] != "retry" {
+		t.Fatalf("outcome = %s, want retry", got[0])
+	}
+
+	stored, err := models.Jobs.GetById(context.Background(), job.Id)
+	if err != nil || stored.Status != database.JobStatusPending || stored.Attempts != 1 || stored.LastError != "unavailable" {
+		t.Fatalf("job = %+v, %v; want it pending after one attempt", stored, err)
+	}
+	if wait := stored.RunAt.Sub(start); wait < retryBase || wait > retryBase+time.Second {
+		t.Fatalf("job retries after %v, want %v", wait, retryBase)
+	}
+}
+
+func TestQueueFailsPermanentError(t *testing.T) {
+	models := database.NewMemoryModels()
+	q := New(models.Jobs, Config{Concurrency: 1, Lease: time.Minute, MaxAttempts: 3})
+
+	Handle(q, testJob, func(context.Context, payload) error {
+		return Permanent(errors.New("bad payload"))
+	})
+
+	job, err := testJob.Enqueue(context.Background(), q, payload{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if got := runQueue(t, q, 1); got[0] != "failed" {
+		t.Fatalf("outcome = %s, want failed", got[0])
+	}
+
+	stored, err := models.Jobs.GetById(context.Background(), job.Id)
+	if err != nil || stored.Status != database.JobStatusFailed || stored.Attempts != 1 {
+		t.Fatalf("job = %+v, %v; want it failed after one attempt", stored, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package logging builds the application's structured logger and carries per-request
+// loggers in contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values never reach the log, wherever they occur.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"email":         true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+}
+
+// New returns a logger that writes JSON, or logfmt-style text when format is "text", at
+// level and above, and redacts sensitive attributes.
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// redact hides the values of attributes with a sensitive key, including ones inside
+// groups, such as a group of request headers. A key is sensitive when it, or its last
+// underscore-separated word, is in sensitiveKeys, so "user_email" is hidden like "email".
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	key := strings.ToLower(a.Key)
+	if i := strings.LastIndexByte(key, '_'); i >= 0 {
+		key = key[i+1:]
+	}
+	if sensitiveKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, or the default logger if it carries none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,104 @@
+// Package mail renders the application's emails from templates and sends them over SMTP.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// UnsubscribeURL, if set, is sent in List-Unsubscribe headers so that mail clients can
+	// offer one-click unsubscribing.
+	UnsubscribeURL string
+}
+
+// Bytes returns the message in RFC 5322 form, as a multipart/alternative MIME message
+// from the given address.
+func (m Message) Bytes(from *mail.Address, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + from.String(),
+		"To: " + (&mail.Address{Address: m.To}).String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + date.Format(time.RFC1123Z),
+		"Message-ID: " + messageId(from),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + body.Boundary(),
+	}
+	if m.UnsubscribeURL != "" {
+		header = append(header,
+			"List-Unsubscribe: <"+m.UnsubscribeURL+">",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+
+	var out bytes.Buffer
+	for _, line := range header {
+		if strings.ContainsAny(line, "\r\n") {
+			return nil, fmt.Errorf("mail: header %q contains a line break", line)
+		}
+		out.WriteString(line + "\r\n")
+	}
+	out.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// messageId returns a unique Message-ID in the domain of the sender's address.
+func messageId(from *mail.Address) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from.Address, "@"); ok {
+		domain = d
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
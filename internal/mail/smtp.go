@@ -0,0 +1,81 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPSender sends messages through an SMTP server. It upgrades the connection with
+// STARTTLS when the server offers it and authenticates when a username is set, so it
+// works both with a mail provider and with a local catch-all server such as Mailpit.
+type SMTPSender struct {
+	Addr     string
+	Username string
+	Password string
+	From     *mail.Address
+}
+
+// Send delivers a message, giving up when ctx is done.
+func (s *SMTPSender) Send(ctx context.Context, m Message) error {
+	data, err := m.Bytes(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.From.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(m.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
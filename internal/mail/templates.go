@@ -0,0 +1,147 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// DefaultLocale is the locale emails are written in when the recipient's locale has no
+// translation of a template.
+const DefaultLocale = "en"
+
+// Templates every locale may translate. DefaultLocale must have all of them.
+const (
+	TemplateInvitation    = "invitation"
+	TemplateReminder      = "reminder"
+	TemplateCancellation  = "cancellation"
+	TemplatePasswordReset = "password_reset"
+)
+
+var templateNames = []string{TemplateInvitation, TemplateReminder, TemplateCancellation, TemplatePasswordReset}
+
+// Templates holds the email templates of every locale. A template is a pair of files in
+// templates/<locale>: <name>.txt defines "subject" and "text", and <name>.html defines
+// "content", which templates/layout.html wraps. Both can use the "footer" defined in the
+// locale's footer.txt and footer.html.
+type Templates struct {
+	locales map[string]map[string]*emailTemplate
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates.
+func LoadTemplates() (*Templates, error) {
+	entries, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{locales: make(map[string]map[string]*emailTemplate)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+
+		t.locales[locale] = make(map[string]*emailTemplate)
+		for _, name := range templateNames {
+			tmpl, err := parseTemplate(locale, name)
+			if err != nil {
+				return nil, fmt.Errorf("mail: template %s/%s: %w", locale, name, err)
+			}
+			if tmpl != nil {
+				t.locales[locale][name] = tmpl
+			}
+		}
+	}
+
+	for _, name := range templateNames {
+		if t.locales[DefaultLocale][name] == nil {
+			return nil, fmt.Errorf("mail: template %s/%s is missing", DefaultLocale, name)
+		}
+	}
+	return t, nil
+}
+
+// parseTemplate parses a template of a locale, or returns nil if the locale does not
+// translate it.
+func parseTemplate(locale, name string) (*emailTemplate, error) {
+	dir := "templates/" + locale + "/"
+	if _, err := fs.Stat(templateFS, dir+name+".txt"); err != nil {
+		return nil, nil
+	}
+
+	text, err := texttemplate.New(name).Option("missingkey=error").
+		ParseFS(templateFS, dir+"footer.txt", dir+name+".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New(name).Option("missingkey=error").
+		ParseFS(templateFS, "templates/layout.html", dir+"footer.html", dir+name+".html")
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailTemplate{text: text, html: html}, nil
+}
+
+// Locales returns the locales that have templates, sorted.
+func (t *Templates) Locales() []string {
+	return slices.Sorted(maps.Keys(t.locales))
+}
+
+// HasLocale reports whether there are templates for locale.
+func (t *Templates) HasLocale(locale string) bool {
+	_, ok := t.locales[locale]
+	return ok
+}
+
+// Render renders the named template in locale, or in DefaultLocale if locale does not
+// translate it, into a message without a recipient. data is available to the template
+// along with "Locale" and "Subject".
+func (t *Templates) Render(name, locale string, data map[string]any) (Message, error) {
+	tmpl := t.locales[locale][name]
+	if tmpl == nil {
+		locale = DefaultLocale
+		tmpl = t.locales[locale][name]
+	}
+	if tmpl == nil {
+		return Message{}, fmt.Errorf("mail: no template %q", name)
+	}
+
+	data = maps.Clone(data)
+	data["Locale"] = locale
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	data["Subject"] = strings.TrimSpace(subject.String())
+
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: data["Subject"].(string),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore forgets the buckets that have refilled.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in memory. Limits are per process, so when several instances
+// of the API run behind a load balancer a client gets the limit of each instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+	r := b.Take(policy, now)
+	b.fullAt = b.FullAt(policy)
+	return r, nil
+}
+
+// sweep forgets the buckets that are full again, as a new bucket is full too, so memory
+// only grows with the number of clients active recently.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package ratelimit limits how often a client may make requests with token buckets, kept
+// in a Store so that they can be shared between instances of the API.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket: it holds up to Limit tokens, a request takes one, and an empty
+// bucket refills completely over Period. A client may therefore make Limit requests in a
+// burst and Limit requests per Period on average.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", such as "10/1m" for ten
+// requests a minute.
+func ParsePolicy(name, s string) (Policy, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("%q is not a rate such as 10/1m", s)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return Policy{}, fmt.Errorf("%q: the limit must be a whole number of at least 1", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("%q: the period must be a positive duration such as 1m", s)
+	}
+
+	return Policy{Name: name, Limit: n, Period: d}, nil
+}
+
+// String returns the policy in the form ParsePolicy reads.
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%s", p.Limit, p.Period)
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+	// Limit and Remaining are the capacity of the bucket and the whole tokens left in it.
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, when the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of every client. Take refills the bucket named key according to
+// policy, then takes a token from it if one is available. It must be safe for concurrent
+// use, and Take must be atomic for a key.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// Bucket is the state of a token bucket, for stores to keep. The zero Bucket is full.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time passed since it was last updated and takes a token
+// from it if one is available.
+func (b *Bucket) Take(policy Policy, now time.Time) Result {
+	limit := float64(policy.Limit)
+	perToken := policy.Period / time.Duration(policy.Limit)
+
+	if b.Updated.IsZero() {
+		b.Tokens = limit
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(limit, b.Tokens+elapsed.Seconds()/perToken.Seconds())
+	}
+	b.Updated = now
+
+	r := Result{Limit: policy.Limit}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = time.Duration((1 - b.Tokens) * float64(perToken))
+	}
+
+	r.Remaining = int(b.Tokens)
+	r.Reset = time.Duration((limit - b.Tokens) * float64(perToken))
+	return r
+}
+
+// FullAt returns when the bucket will be full again, after which forgetting it changes
+// nothing.
+func (b *Bucket) FullAt(policy Policy) time.Time {
+	perToken := policy.Period / time.Duration(policy.Limit)
+	return b.Updated.Add(time.Duration((float64(policy.Limit) - b.Tokens) * float64(perToken)))
+}
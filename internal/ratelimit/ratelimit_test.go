@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Policy
+		wantErr bool
+	}{
+		{in: "10/1m", want: Policy{Name: "auth", Limit: 10, Period: time.Minute}},
+		{in: " 5/30s ", want: Policy{Name: "auth", Limit: 5, Period: 30 * time.Second}},
+		{in: "10", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+		{in: "10/0s", wantErr: true},
+		{in: "10/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy("auth", tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	// Three tokens refilling over three seconds, so one a second.
+	policy := Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		at   time.Duration
+		want Result
+	}{
+		{"a new bucket is full", 0, Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+		{"second token", 0, Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}},
+		{"last token", 0, Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		{"empty", 0, Result{Limit: 3, Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second}},
+		{"half a token refilled", 500 * time.Millisecond, Result{Limit: 3, Remaining: 0, Reset: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{"a token and a half refilled", 1500 * time.Millisecond, Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 2500 * time.Millisecond}},
+		{"refill stops at the limit", time.Minute, Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+	}
+
+	var b Bucket
+	for _, tt := range tests {
+		if got := b.Take(policy, start.Add(tt.at)); got != tt.want {
+			t.Fatalf("%s: Take = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBucketFullAt(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 4, Period: time.Minute}
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		takes int
+		want  time.Duration
+	}{
+		{0, 0},
+		{1, 15 * time.Second},
+		{3, 45 * time.Second},
+		{6, time.Minute},
+	}
+
+	for _, tt := range tests {
+		b := Bucket{Tokens: 4, Updated: start}
+		for range tt.takes {
+			b.Take(policy, start)
+		}
+		if got := b.FullAt(policy); !got.Equal(start.Add(tt.want)) {
+			t.Errorf("FullAt after %d takes = %v, want %v", tt.takes, got.Sub(start), tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	// Two tokens refilling over a minute, so a bucket that gave one token is full again
+	// 30 seconds later. Buckets are only swept once a minute.
+	policy := Policy{Name: "test", Limit: 2, Period: time.Minute}
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		at   time.Duration
+		key  string
+		want []string
+	}{
+		{0, "a", []string{"a"}},
+		{30 * time.Second, "b", []string{"a", "b"}},
+		{45 * time.Second, "c", []string{"a", "b", "c"}},
+		{60 * time.Second, "d", []string{"c", "d"}},
+		{90 * time.Second, "d", []string{"c", "d"}},
+		{120 * time.Second, "e", []string{"e"}},
+	}
+
+	s := NewMemoryStore()
+	for _, tt := range tests {
+		s.now = func() time.Time { return start.Add(tt.at) }
+
+		if _, err := s.Take(context.Background(), tt.key, policy); err != nil {
+			t.Fatalf("Take(%s): %v", tt.key, err)
+		}
+
+		var keys []string
+		for key := range s.buckets {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, tt.want) {
+			t.Fatalf("after taking %s at %v: buckets %v, want %v", tt.key, tt.at, keys, tt.want)
+		}
+	}
+}
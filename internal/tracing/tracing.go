@@ -0,0 +1,86 @@
+// Package tracing sets up OpenTelemetry tracing for the application.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"go-event-crud/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Setup installs the W3C trace context and baggage propagators and, when tracing is
+// enabled, a global tracer provider that exports spans as configured. It returns a
+// function that flushes buffered spans and shuts the provider down.
+//
+// Spans go to the OTLP/HTTP collector at cfg.OTLPEndpoint if one is set, and otherwise to
+// cfg.File or, failing that, stdout, which is handy locally.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's sampling decision so traces that started upstream stay whole.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	if cfg.OTLPEndpoint != "" {
+		// Like OTEL_EXPORTER_OTLP_ENDPOINT, the endpoint is the collector's base URL and
+		// traces go to its /v1/traces path.
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/")+"/v1/traces"))
+	}
+
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return fileExporter{SpanExporter: exporter, file: file}, nil
+	}
+
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// fileExporter closes the file spans are written to when it is shut down.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}